@@ -1,18 +1,21 @@
 package main
 
 import (
+	"context"
 	"crypto/sha256"
 	"fmt"
 	"go-http-server/internal/headers"
 	"go-http-server/internal/request"
 	"go-http-server/internal/response"
+	"go-http-server/internal/router"
 	"go-http-server/internal/server"
+	"go-http-server/internal/websocket"
 	"log"
 	"net/http"
 	"os"
 	"os/signal"
-	"strings"
 	"syscall"
+	"time"
 )
 
 var HtmlResponses = map[response.StatusCode]string{
@@ -47,12 +50,17 @@ var HtmlResponses = map[response.StatusCode]string{
 
 const port = 8080
 
+const (
+	readHeaderTimeout = 10 * time.Second
+	readTimeout       = 30 * time.Second
+	writeTimeout      = 60 * time.Second
+	idleTimeout       = 120 * time.Second
+	shutdownTimeout   = 10 * time.Second
+)
+
 func writeHTMLResponse(w *response.Writer, status response.StatusCode) {
-	body := HtmlResponses[status]
-	h := response.GetDefaultHeaders(len(body))
-	w.WriteStatusLine(status)
-	w.WriteHeaders(h)
-	w.WriteBody([]byte(body))
+	w.SetStatus(status)
+	w.Write([]byte(HtmlResponses[status]))
 }
 
 func proxyHttpBin(w *response.Writer, subPath string) {
@@ -83,55 +91,79 @@ func proxyHttpBin(w *response.Writer, subPath string) {
 		body = append(body, buf[:n]...)
 		w.WriteChunkedBody(buf[:n])
 	}
-	w.WriteChunkedBodyDone()
 
 	trailers := headers.NewHeaders()
 	trailers.Set("X-Content-SHA256", fmt.Sprintf("%x", sha256.Sum256(body)))
 	trailers.Set("X-Content-Length", fmt.Sprintf("%d", len(body)))
 
-	w.WriteHeaders(trailers)
+	w.WriteTrailers(trailers)
 }
 
 func handleVideoReq(w *response.Writer) {
 	f, _ := os.ReadFile("assets/vim.mp4")
 
-	h := response.GetDefaultHeaders(0)
-	h.Replace("content-type", "video/mp4")
-	h.Replace("content-length", fmt.Sprintf("%d", len(f)))
-
-	w.WriteStatusLine(response.StatusOK)
-	w.WriteHeaders(h)
-	w.WriteBody(f)
+	w.Header().Set("content-type", "video/mp4")
+	w.Header().Set("content-length", fmt.Sprintf("%d", len(f)))
+	w.Write(f)
 }
 
-func main() {
-	handler := func(w *response.Writer, req *request.Request) {
-		if after, ok := strings.CutPrefix(req.RequestLine.RequestTarget, "/httpbin/"); ok {
-			proxyHttpBin(w, after)
+// handleEcho sends every WebSocket message back to the client.
+func handleEcho(w *response.Writer, req *request.Request) {
+	conn, err := websocket.Upgrade(w, req)
+	if err != nil {
+		return
+	}
+	defer conn.Close()
+	for {
+		typ, msg, err := conn.ReadMessage()
+		if err != nil {
 			return
 		}
-
-		switch req.RequestLine.RequestTarget {
-		case "/yourproblem":
-			writeHTMLResponse(w, response.StatusBadRequest)
-		case "/myproblem":
-			writeHTMLResponse(w, response.StatusInternalServerError)
-		case "/video":
-			handleVideoReq(w)
-		default:
-			writeHTMLResponse(w, response.StatusOK)
+		if err := conn.WriteMessage(typ, msg); err != nil {
+			return
 		}
 	}
+}
 
-	server, err := server.Serve(port, handler)
+func main() {
+	r := router.New()
+	r.Handle("GET", "/httpbin/*", func(w *response.Writer, req *request.Request) {
+		proxyHttpBin(w, req.Param(router.WildcardParam))
+	})
+	r.Handle("GET", "/yourproblem", func(w *response.Writer, _ *request.Request) {
+		writeHTMLResponse(w, response.StatusBadRequest)
+	})
+	r.Handle("GET", "/myproblem", func(w *response.Writer, _ *request.Request) {
+		writeHTMLResponse(w, response.StatusInternalServerError)
+	})
+	r.Handle("GET", "/video", func(w *response.Writer, _ *request.Request) {
+		handleVideoReq(w)
+	})
+	r.Handle("GET", "/echo", handleEcho)
+	r.NotFound = func(w *response.Writer, _ *request.Request) {
+		writeHTMLResponse(w, response.StatusOK)
+	}
+
+	server, err := server.Serve(port, server.Chain(r.Dispatch, server.Logger),
+		server.WithReadHeaderTimeout(readHeaderTimeout),
+		server.WithReadTimeout(readTimeout),
+		server.WithWriteTimeout(writeTimeout),
+		server.WithIdleTimeout(idleTimeout),
+	)
 	if err != nil {
 		log.Fatalf("Error starting server: %v", err)
 	}
-	defer server.Close()
 	log.Println("Server started on port", port)
 
 	sigChan := make(chan os.Signal, 1)
 	signal.Notify(sigChan, syscall.SIGINT, syscall.SIGTERM)
 	<-sigChan
+
+	ctx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+	defer cancel()
+	if err := server.Shutdown(ctx); err != nil {
+		log.Printf("Error stopping server: %v", err)
+		return
+	}
 	log.Println("Server gracefully stopped")
 }
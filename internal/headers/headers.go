@@ -4,16 +4,39 @@ import (
 	"bytes"
 	"errors"
 	"go-http-server/internal/tokens"
+	"iter"
+	"slices"
 	"strings"
 )
 
-type Headers map[string]string
+// Headers holds field lines in the order they were added. Names keep the
+// case they were given with and are matched case-insensitively.
+type Headers struct {
+	fields  []Field
+	lenient bool
+}
+
+// Field is a single field line.
+type Field struct {
+	Name  string
+	Value string
+}
 
-func NewHeaders() Headers {
-	return make(Headers)
+func NewHeaders() *Headers {
+	return &Headers{}
 }
 
-func (h Headers) Parse(data []byte) (n int, done bool, err error) {
+// SetLenient makes Parse unfold obs-fold continuation lines into a single
+// space instead of rejecting them, for legacy clients.
+func (h *Headers) SetLenient(lenient bool) {
+	h.lenient = lenient
+}
+
+// Parse reads one field line from data. Whitespace between the field name
+// and the colon is rejected, and so is a line starting with whitespace
+// (obs-fold, RFC 9112 5.2) unless lenient parsing is on and there is a
+// previous field for it to continue.
+func (h *Headers) Parse(data []byte) (n int, done bool, err error) {
 	if len(data) <= 0 {
 		return 0, false, errors.New("headers error: missing end of headers")
 	}
@@ -39,56 +62,187 @@ func (h Headers) Parse(data []byte) (n int, done bool, err error) {
 		return 0, false, nil
 	}
 
-	if colonIndex <= 0 || data[colonIndex-1] == tokens.SP {
+	if isWhitespace(data[0]) {
+		if len(h.fields) == 0 {
+			return 0, false, errors.New("headers error: whitespace before first field")
+		}
+		if !h.lenient {
+			return 0, false, errors.New("headers error: obsolete line folding")
+		}
+		if err := h.unfold(data[:crlfIndex]); err != nil {
+			return 0, false, err
+		}
+		return crlfIndex + len(tokens.CRLF), false, nil
+	}
+
+	if colonIndex <= 0 || isWhitespace(data[colonIndex-1]) {
 		return 0, false, errors.New("headers error: 400 (bad request)")
 	}
 
-	fieldName := bytes.TrimSpace(data[:colonIndex])
-	fieldValue := bytes.TrimSpace(data[colonIndex+1 : crlfIndex])
+	fieldName := data[:colonIndex]
+	fieldValue := bytes.Trim(data[colonIndex+1:crlfIndex], " \t")
 
-	if !isValidFieldName(fieldName) {
+	if !IsValidFieldName(string(fieldName)) {
 		return 0, false, errors.New("headers error: invalid character in field name")
 	}
+	if !IsValidFieldValue(string(fieldValue)) {
+		return 0, false, errors.New("headers error: invalid character in field value")
+	}
 
-	h.Set(string(fieldName), string(fieldValue))
+	h.Add(string(fieldName), string(fieldValue))
 
 	return crlfIndex + len(tokens.CRLF), false, nil
 }
 
-func (h Headers) Get(key string) string {
-	return h[strings.ToLower(key)]
+// unfold appends an obs-fold continuation line to the last field value.
+func (h *Headers) unfold(line []byte) error {
+	continuation := string(bytes.Trim(line, " \t"))
+	if !IsValidFieldValue(continuation) {
+		return errors.New("headers error: invalid character in field value")
+	}
+	last := &h.fields[len(h.fields)-1]
+	if last.Value == "" {
+		last.Value = continuation
+	} else if continuation != "" {
+		last.Value += " " + continuation
+	}
+	return nil
+}
+
+// Get returns the values of every field line named key, joined with ", ".
+func (h *Headers) Get(key string) string {
+	return strings.Join(h.Values(key), ", ")
+}
+
+// Values returns the value of every field line named key, in order.
+func (h *Headers) Values(key string) []string {
+	var values []string
+	for _, f := range h.fields {
+		if strings.EqualFold(f.Name, key) {
+			values = append(values, f.Value)
+		}
+	}
+	return values
+}
+
+// Has reports whether there is a field line named key, even an empty one.
+func (h *Headers) Has(key string) bool {
+	return h.index(key) >= 0
+}
+
+// Add appends a new field line.
+func (h *Headers) Add(key string, val string) {
+	h.fields = append(h.fields, Field{Name: key, Value: val})
+}
+
+// Set appends val to the list in the first field line named key, or adds a
+// field line if there is none. Set-Cookie values cannot be combined into a
+// list (RFC 9110 5.3), so they always get a field line of their own.
+func (h *Headers) Set(key string, val string) {
+	i := h.index(key)
+	switch {
+	case i < 0 || strings.EqualFold(key, "set-cookie"):
+		h.Add(key, val)
+	case h.fields[i].Value == "":
+		h.fields[i].Value = val
+	default:
+		h.fields[i].Value += ", " + val
+	}
 }
 
-func (h Headers) Set(key string, val string) {
-	lowerKey := strings.ToLower(key)
-	if existing, ok := h[lowerKey]; ok && existing != "" {
-		h[lowerKey] = existing + ", " + val
-	} else {
-		h[lowerKey] = val
+// HasToken reports whether the comma-separated list in the field key
+// contains token, compared case-insensitively.
+func (h *Headers) HasToken(key string, token string) bool {
+	for _, v := range strings.Split(h.Get(key), ",") {
+		if strings.EqualFold(strings.TrimSpace(v), token) {
+			return true
+		}
 	}
+	return false
 }
 
-func (h Headers) Delete(key string) {
-	delete(h, strings.ToLower(key))
+// Delete removes every field line named key.
+func (h *Headers) Delete(key string) {
+	h.fields = slices.DeleteFunc(h.fields, func(f Field) bool {
+		return strings.EqualFold(f.Name, key)
+	})
 }
 
-func (h Headers) Replace(key string, val string) {
+// Replace removes every field line named key and adds one with val.
+func (h *Headers) Replace(key string, val string) {
 	h.Delete(key)
-	h[strings.ToLower(key)] = val
-}
-
-func isValidFieldName(fieldName []byte) bool {
-	for _, b := range fieldName {
-		switch {
-		case 'A' <= b && b <= 'Z':
-		case 'a' <= b && b <= 'z':
-		case '0' <= b && b <= '9':
-		case b == '!' || b == '#' || b == '$' || b == '%' || b == '&' ||
-			b == '\'' || b == '*' || b == '+' || b == '-' || b == '.' ||
-			b == '^' || b == '_' || b == '`' || b == '|' || b == '~':
-		default:
+	h.Add(key, val)
+}
+
+// Len returns the number of field lines.
+func (h *Headers) Len() int {
+	return len(h.fields)
+}
+
+// All iterates over the field lines in order.
+func (h *Headers) All() iter.Seq2[string, string] {
+	return func(yield func(string, string) bool) {
+		for _, f := range h.fields {
+			if !yield(f.Name, f.Value) {
+				return
+			}
+		}
+	}
+}
+
+func (h *Headers) index(key string) int {
+	return slices.IndexFunc(h.fields, func(f Field) bool {
+		return strings.EqualFold(f.Name, key)
+	})
+}
+
+// IsValidFieldName reports whether name is a non-empty token.
+func IsValidFieldName(name string) bool {
+	if name == "" {
+		return false
+	}
+	for i := 0; i < len(name); i++ {
+		if !IsTokenChar(name[i]) {
+			return false
+		}
+	}
+	return true
+}
+
+// IsValidFieldValue checks the field-value grammar from RFC 9110 5.5:
+// visible characters and obs-text, with SP and HTAB allowed only between
+// them. Control characters such as CR, LF and NUL are never allowed.
+func IsValidFieldValue(value string) bool {
+	if value == "" {
+		return true
+	}
+	if isWhitespace(value[0]) || isWhitespace(value[len(value)-1]) {
+		return false
+	}
+	for i := 0; i < len(value); i++ {
+		b := value[i]
+		if (b < tokens.SP && b != tokens.HTAB) || b == 0x7f {
 			return false
 		}
 	}
 	return true
 }
+
+func isWhitespace(b byte) bool {
+	return b == tokens.SP || b == tokens.HTAB
+}
+
+// IsTokenChar reports whether b is a tchar as defined in RFC 9110 5.6.2.
+func IsTokenChar(b byte) bool {
+	switch {
+	case 'A' <= b && b <= 'Z':
+	case 'a' <= b && b <= 'z':
+	case '0' <= b && b <= '9':
+	case b == '!' || b == '#' || b == '$' || b == '%' || b == '&' ||
+		b == '\'' || b == '*' || b == '+' || b == '-' || b == '.' ||
+		b == '^' || b == '_' || b == '`' || b == '|' || b == '~':
+	default:
+		return false
+	}
+	return true
+}
@@ -15,40 +15,46 @@ func TestHeadersParse(t *testing.T) {
 	n, done, err := headers.Parse(data)
 	require.NoError(t, err)
 	require.NotNil(t, headers)
-	assert.Equal(t, "localhost:8080", headers["host"])
+	assert.Equal(t, "localhost:8080", headers.Get("host"))
 	assert.Equal(t, 22, n)
 	assert.False(t, done)
 
 	// Test: Valid single header with extra whitespace
 	headers = NewHeaders()
-	data = []byte("  Content-Type:   text/html  \r\n\r\n")
+	data = []byte("Content-Type:   text/html  \r\n\r\n")
 	n, done, err = headers.Parse(data)
 	require.NoError(t, err)
-	assert.Equal(t, "text/html", headers["content-type"])
-	assert.Equal(t, 31, n)
+	assert.Equal(t, "text/html", headers.Get("content-type"))
+	assert.Equal(t, 29, n)
 	assert.False(t, done)
 
+	// Test: Whitespace before the first field
+	headers = NewHeaders()
+	headers.SetLenient(true)
+	_, _, err = headers.Parse([]byte("  Content-Type: text/html\r\n\r\n"))
+	require.Error(t, err)
+
 	// Test: Valid 2 headers with existing headers
 	headers = NewHeaders()
-	headers["connection"] = "keep-alive"
+	headers.Set("connection", "keep-alive")
 	data = []byte("Host: example.com\r\nUser-Agent: curl/8.1\r\n\r\n")
 
 	n1, done, err := headers.Parse(data)
 	require.NoError(t, err)
 	assert.False(t, done)
-	assert.Equal(t, "example.com", headers["host"])
+	assert.Equal(t, "example.com", headers.Get("host"))
 
 	fmt.Println(n1)
 	fmt.Println(string(data[n1:]))
 	n2, done, err := headers.Parse(data[n1:])
 	require.NoError(t, err)
 	assert.False(t, done)
-	assert.Equal(t, "curl/8.1", headers["user-agent"])
+	assert.Equal(t, "curl/8.1", headers.Get("user-agent"))
 
 	_, done, err = headers.Parse(data[n1+n2:])
 	require.NoError(t, err)
 	assert.True(t, done)
-	assert.Equal(t, "keep-alive", headers["connection"])
+	assert.Equal(t, "keep-alive", headers.Get("connection"))
 
 	// Test: Valid done (just CRLF)
 	headers = NewHeaders()
@@ -57,7 +63,7 @@ func TestHeadersParse(t *testing.T) {
 	require.NoError(t, err)
 	assert.True(t, done)
 	assert.Equal(t, 2, n)
-	assert.Empty(t, headers)
+	assert.Equal(t, 0, headers.Len())
 
 	// Test: Invalid spacing header
 	headers = NewHeaders()
@@ -72,7 +78,7 @@ func TestHeadersParse(t *testing.T) {
 	data = []byte("X-Custom-Header: value\r\n\r\n")
 	_, _, err = headers.Parse(data)
 	require.NoError(t, err)
-	assert.Equal(t, "value", headers["x-custom-header"])
+	assert.Equal(t, "value", headers.Get("x-custom-header"))
 
 	// Test: Invalid character in header key
 	headers = NewHeaders()
@@ -84,11 +90,161 @@ func TestHeadersParse(t *testing.T) {
 
 	// Test: Starting header matches header to be parsed
 	headers = NewHeaders()
-	headers["set-person"] = "alice"
+	headers.Set("set-person", "alice")
 	data = []byte("Set-Person: bob\r\n\r\n")
 	n, done, err = headers.Parse(data)
 	require.NoError(t, err)
 	assert.Equal(t, 17, n)
-	assert.Equal(t, "alice, bob", headers["set-person"])
+	assert.Equal(t, "alice, bob", headers.Get("set-person"))
+	assert.False(t, done)
+}
+
+func TestHeadersHasToken(t *testing.T) {
+	headers := NewHeaders()
+	headers.Set("Connection", "Keep-Alive, Upgrade")
+	assert.True(t, headers.HasToken("connection", "keep-alive"))
+	assert.True(t, headers.HasToken("connection", "upgrade"))
+	assert.False(t, headers.HasToken("connection", "close"))
+	assert.False(t, headers.HasToken("upgrade", "websocket"))
+}
+
+func TestHeadersFieldLines(t *testing.T) {
+	// Test: Repeated fields keep their own lines, order and case
+	headers := NewHeaders()
+	data := []byte("Set-Cookie: a=1; Expires=Wed, 21 Oct 2026 07:28:00 GMT\r\nHOST: example.com\r\nset-cookie: b=2\r\n\r\n")
+	for {
+		n, done, err := headers.Parse(data)
+		require.NoError(t, err)
+		data = data[n:]
+		if done {
+			break
+		}
+	}
+	assert.Equal(t, 3, headers.Len())
+	assert.Equal(t, []string{"a=1; Expires=Wed, 21 Oct 2026 07:28:00 GMT", "b=2"}, headers.Values("Set-Cookie"))
+	assert.Equal(t, "a=1; Expires=Wed, 21 Oct 2026 07:28:00 GMT, b=2", headers.Get("set-cookie"))
+
+	names := []string{}
+	for name := range headers.All() {
+		names = append(names, name)
+	}
+	assert.Equal(t, []string{"Set-Cookie", "HOST", "set-cookie"}, names)
+
+	// Test: Set joins into one line, Add starts a new one
+	headers = NewHeaders()
+	headers.Set("Vary", "Accept")
+	headers.Set("Vary", "Origin")
+	headers.Add("Vary", "Cookie")
+	assert.Equal(t, []string{"Accept, Origin", "Cookie"}, headers.Values("vary"))
+
+	// Test: Set never combines Set-Cookie
+	headers.Set("Set-Cookie", "a=1")
+	headers.Set("Set-Cookie", "b=2")
+	assert.Equal(t, []string{"a=1", "b=2"}, headers.Values("set-cookie"))
+
+	// Test: Delete and Replace remove every line
+	headers.Replace("vary", "*")
+	assert.Equal(t, []string{"*"}, headers.Values("Vary"))
+	headers.Delete("SET-COOKIE")
+	assert.False(t, headers.Has("set-cookie"))
+	assert.Nil(t, headers.Values("set-cookie"))
+
+	// Test: Has sees empty fields
+	headers.Add("X-Empty", "")
+	assert.True(t, headers.Has("x-empty"))
+}
+
+func TestHeadersFieldValues(t *testing.T) {
+	// Test: Tabs, inner spaces and obs-text are allowed
+	headers := NewHeaders()
+	data := []byte("X-Value: \ta \t b\xe9\t \r\n\r\n")
+	n, done, err := headers.Parse(data)
+	require.NoError(t, err)
+	assert.Equal(t, len(data)-2, n)
 	assert.False(t, done)
+	assert.Equal(t, "a \t b\xe9", headers.Get("x-value"))
+
+	// Test: Empty value
+	headers = NewHeaders()
+	_, _, err = headers.Parse([]byte("X-Empty:\r\n\r\n"))
+	require.NoError(t, err)
+	assert.True(t, headers.Has("x-empty"))
+
+	// Test: Control characters are rejected
+	for _, value := range []string{"a\x00b", "a\rb", "a\nb", "a\x7fb", "a\x1bb"} {
+		headers = NewHeaders()
+		_, _, err = headers.Parse([]byte("X-Value: " + value + "\r\n\r\n"))
+		assert.Error(t, err, value)
+	}
+
+	// Test: Value grammar
+	assert.True(t, IsValidFieldValue(""))
+	assert.True(t, IsValidFieldValue("a, b;\tc"))
+	assert.False(t, IsValidFieldValue(" a"))
+	assert.False(t, IsValidFieldValue("a\t"))
+	assert.False(t, IsValidFieldValue("a\r\nX-Injected: 1"))
+}
+
+func TestHeadersObsFold(t *testing.T) {
+	parseAll := func(headers *Headers, data string) error {
+		b := []byte(data)
+		for {
+			n, done, err := headers.Parse(b)
+			if err != nil {
+				return err
+			}
+			b = b[n:]
+			if done {
+				return nil
+			}
+		}
+	}
+
+	// Test: Tab before colon
+	headers := NewHeaders()
+	_, _, err := headers.Parse([]byte("Host\t: localhost:8080\r\n\r\n"))
+	require.Error(t, err)
+
+	// Test: Space before colon after a valid field
+	headers = NewHeaders()
+	err = parseAll(headers, "Accept: */*\r\nHost : localhost:8080\r\n\r\n")
+	require.Error(t, err)
+
+	// Test: Obs-fold rejected in strict mode
+	headers = NewHeaders()
+	err = parseAll(headers, "X-Long: first\r\n second\r\n\r\n")
+	require.Error(t, err)
+
+	// Test: Obs-fold with tab rejected in strict mode
+	headers = NewHeaders()
+	err = parseAll(headers, "X-Long: first\r\n\tsecond\r\n\r\n")
+	require.Error(t, err)
+
+	// Test: Obs-fold unfolded in lenient mode
+	headers = NewHeaders()
+	headers.SetLenient(true)
+	err = parseAll(headers, "X-Long: first\r\n  second\r\n\tthird  \r\nHost: localhost:8080\r\n\r\n")
+	require.NoError(t, err)
+	assert.Equal(t, "first second third", headers.Get("x-long"))
+	assert.Equal(t, "localhost:8080", headers.Get("host"))
+	assert.Equal(t, 2, headers.Len())
+
+	// Test: Obs-fold onto an empty value in lenient mode
+	headers = NewHeaders()
+	headers.SetLenient(true)
+	err = parseAll(headers, "X-Long:\r\n value\r\n\r\n")
+	require.NoError(t, err)
+	assert.Equal(t, "value", headers.Get("x-long"))
+
+	// Test: Lenient mode still rejects whitespace before colon
+	headers = NewHeaders()
+	headers.SetLenient(true)
+	err = parseAll(headers, "Accept: */*\r\nHost\t: localhost:8080\r\n\r\n")
+	require.Error(t, err)
+
+	// Test: Lenient mode still validates continuation values
+	headers = NewHeaders()
+	headers.SetLenient(true)
+	err = parseAll(headers, "X-Long: first\r\n bad\x00\r\n\r\n")
+	require.Error(t, err)
 }
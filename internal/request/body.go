@@ -0,0 +1,99 @@
+package request
+
+import (
+	"errors"
+	"io"
+)
+
+// maxDrainBytes is how much of an unread body Close discards to keep the
+// connection usable for the next request.
+const maxDrainBytes = 256 << 10
+
+var (
+	errBodyClosed     = errors.New("request error: read on closed body")
+	errBodyNotDrained = errors.New("request error: unread body too large to discard")
+)
+
+// NoBody is the Body of requests without content.
+var NoBody io.ReadCloser = noBody{}
+
+type noBody struct{}
+
+func (noBody) Read([]byte) (int, error) { return 0, io.EOF }
+func (noBody) Close() error             { return nil }
+
+// body decodes the framing of a request body as it is read from the
+// connection.
+type body struct {
+	reader *Reader
+	req    *Request
+	err    error
+}
+
+func (b *body) Read(p []byte) (int, error) {
+	if len(b.req.pending) == 0 {
+		if b.err != nil {
+			return 0, b.err
+		}
+		if err := b.fill(); err != nil {
+			b.err = err
+			return 0, err
+		}
+	}
+
+	n := copy(p, b.req.pending)
+	b.req.pending = b.req.pending[n:]
+	return n, nil
+}
+
+// fill reads from the connection until decoded bytes are pending or the
+// body is complete.
+func (b *body) fill() error {
+	req := b.req
+	if req.RequestState == Done {
+		return io.EOF
+	}
+
+	if req.RequestState == ParsingBody {
+		if err := req.checkBodySize(req.bodyBytes + req.bodyRemaining); err != nil {
+			return err
+		}
+	}
+
+	req.sendContinue()
+	err := b.reader.readUntil(req, func() bool {
+		return len(req.pending) > 0 || req.RequestState == Done
+	})
+	if err != nil {
+		return err
+	}
+	if len(req.pending) == 0 {
+		return io.EOF
+	}
+	return nil
+}
+
+// Close discards what is left of the body so that the next request on the
+// connection can be read. It fails if the body cannot be read to the end.
+func (b *body) Close() error {
+	if b.err == errBodyClosed {
+		return nil
+	}
+
+	n, err := io.CopyN(io.Discard, b, maxDrainBytes+1)
+	if err == nil || n > maxDrainBytes {
+		err = errBodyNotDrained
+	} else if errors.Is(err, io.EOF) {
+		err = nil
+	}
+
+	b.err = errBodyClosed
+	b.req.pending = nil
+	return err
+}
+
+// ReadBody reads the whole body into memory. It is meant for small
+// requests; large uploads should be read from Body as a stream.
+func (r *Request) ReadBody() ([]byte, error) {
+	return io.ReadAll(r.Body)
+}
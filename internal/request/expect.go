@@ -0,0 +1,53 @@
+package request
+
+import (
+	"errors"
+	"strings"
+)
+
+// ErrExpectationFailed is returned for an Expect header with anything
+// other than 100-continue.
+var ErrExpectationFailed = errors.New("request error: unsupported expectation")
+
+// checkExpect validates the Expect header and records whether the client
+// waits for 100 Continue before sending the body. HTTP/1.0 clients do not
+// know about interim responses, so their 100-continue is ignored (RFC 9110
+// 10.1.1).
+func (r *Request) checkExpect() error {
+	expectContinue := false
+	for _, v := range r.Headers.Values("expect") {
+		for _, expectation := range strings.Split(v, ",") {
+			expectation = strings.TrimSpace(expectation)
+			if !strings.EqualFold(expectation, "100-continue") {
+				return ErrExpectationFailed
+			}
+			expectContinue = true
+		}
+	}
+	r.expectContinue = expectContinue && r.RequestLine.HttpVersion != HTTP10 && r.RequestState != Done
+	return nil
+}
+
+// ExpectsContinue reports whether the client is still waiting for 100
+// Continue before it sends the body.
+func (r *Request) ExpectsContinue() bool {
+	return r.expectContinue
+}
+
+// OnContinue sets fn to be called right before the body is first read from
+// the connection, if the client is waiting for 100 Continue. fn is
+// expected to send the interim response.
+func (r *Request) OnContinue(fn func()) {
+	r.onContinue = fn
+}
+
+// sendContinue calls the OnContinue callback once.
+func (r *Request) sendContinue() {
+	if !r.expectContinue {
+		return
+	}
+	r.expectContinue = false
+	if r.onContinue != nil {
+		r.onContinue()
+	}
+}
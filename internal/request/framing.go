@@ -0,0 +1,104 @@
+package request
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// ErrBadFraming is returned, wrapped, when the length of the request body
+// is missing a clear answer. A proxy in front of the server may have
+// framed the request differently, so the connection must not be reused.
+var ErrBadFraming = errors.New("request error: invalid message framing")
+
+// ErrTransferCodingNotImplemented is returned when a request uses a
+// transfer coding other than chunked.
+var ErrTransferCodingNotImplemented = errors.New("request error: transfer coding not implemented")
+
+// bodyFraming decides how the request body is delimited, following RFC
+// 9112 6.3. It returns whether the body is chunked and, if not, its
+// length.
+func (r *Request) bodyFraming() (bool, int, error) {
+	hasTE := r.Headers.Has("transfer-encoding")
+	hasCL := r.Headers.Has("content-length")
+
+	if hasTE {
+		if hasCL {
+			return false, 0, fmt.Errorf("%w: both Transfer-Encoding and Content-Length", ErrBadFraming)
+		}
+		if r.RequestLine.HttpVersion == HTTP10 {
+			return false, 0, fmt.Errorf("%w: Transfer-Encoding in an HTTP/1.0 request", ErrBadFraming)
+		}
+		if err := checkTransferCodings(r.Headers.Values("transfer-encoding")); err != nil {
+			return false, 0, err
+		}
+		return true, 0, nil
+	}
+
+	if !hasCL {
+		return false, 0, nil
+	}
+	length, err := parseContentLength(r.Headers.Values("content-length"))
+	if err != nil {
+		return false, 0, err
+	}
+	return false, length, nil
+}
+
+// checkTransferCodings requires chunked to be the final coding and to be
+// applied once. It is the only coding the server can decode.
+func checkTransferCodings(values []string) error {
+	codings := []string{}
+	for _, v := range values {
+		for _, coding := range strings.Split(v, ",") {
+			if coding = strings.TrimSpace(coding); coding != "" {
+				codings = append(codings, strings.ToLower(coding))
+			}
+		}
+	}
+	if len(codings) == 0 || codings[len(codings)-1] != "chunked" {
+		return fmt.Errorf("%w: chunked is not the final transfer coding", ErrBadFraming)
+	}
+	for _, coding := range codings[:len(codings)-1] {
+		if coding == "chunked" {
+			return fmt.Errorf("%w: chunked applied more than once", ErrBadFraming)
+		}
+	}
+	if len(codings) > 1 {
+		return ErrTransferCodingNotImplemented
+	}
+	return nil
+}
+
+// parseContentLength accepts repeated Content-Length fields, or a list in
+// one field, only if every value is the same non-negative decimal number.
+func parseContentLength(values []string) (int, error) {
+	length := -1
+	for _, v := range values {
+		for _, item := range strings.Split(v, ",") {
+			item = strings.TrimSpace(item)
+			if item == "" || !isDigits(item) {
+				return 0, fmt.Errorf("%w: invalid Content-Length %q", ErrBadFraming, item)
+			}
+			n, err := strconv.Atoi(item)
+			if err != nil {
+				return 0, fmt.Errorf("%w: Content-Length out of range", ErrBadFraming)
+			}
+			if length >= 0 && n != length {
+				return 0, fmt.Errorf("%w: conflicting Content-Length values", ErrBadFraming)
+			}
+			length = n
+		}
+	}
+	return length, nil
+}
+
+func isDigits(s string) bool {
+	for i := 0; i < len(s); i++ {
+		if !isDigit(s[i]) {
+			return false
+		}
+	}
+	return true
+}
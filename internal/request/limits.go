@@ -0,0 +1,103 @@
+package request
+
+import (
+	"errors"
+	"go-http-server/internal/headers"
+	"go-http-server/internal/tokens"
+)
+
+var (
+	ErrRequestLineTooLong = errors.New("request error: request line too long")
+	ErrHeadersTooLarge    = errors.New("request error: header section too large")
+	ErrBodyTooLarge       = errors.New("request error: body too large")
+	ErrChunkLineTooLong   = errors.New("request error: chunk size line too long")
+)
+
+// maxChunkSizeLineBytes caps a chunk-size line, extensions included, so a
+// line without CRLF cannot grow the read buffer without bound.
+const maxChunkSizeLineBytes = 4 << 10
+
+// Limits bounds the size of a request. A zero field means no limit.
+type Limits struct {
+	MaxRequestLineBytes int
+	// MaxHeaderBytes and MaxHeaderCount also cover the trailer section of
+	// chunked bodies.
+	MaxHeaderBytes int
+	MaxHeaderCount int
+	MaxBodyBytes   int
+}
+
+var DefaultLimits = Limits{
+	MaxRequestLineBytes: 8 << 10,
+	MaxHeaderBytes:      1 << 20,
+	MaxHeaderCount:      100,
+	MaxBodyBytes:        10 << 20,
+}
+
+// SetMaxBodyBytes overrides the body limit for a request whose body has
+// not been read yet. A value of 0 removes the limit.
+func (r *Request) SetMaxBodyBytes(n int) {
+	r.limits.MaxBodyBytes = n
+}
+
+func (r *Request) checkRequestLine(numBytes int, buffered int) error {
+	max := r.limits.MaxRequestLineBytes
+	if max <= 0 {
+		return nil
+	}
+	if numBytes > 0 && numBytes-len(tokens.CRLF) > max {
+		return ErrRequestLineTooLong
+	}
+	if numBytes <= 0 && buffered > max+len(tokens.CRLF) {
+		return ErrRequestLineTooLong
+	}
+	return nil
+}
+
+func checkChunkSizeLine(numBytes int, buffered int) error {
+	if numBytes > 0 && numBytes-len(tokens.CRLF) > maxChunkSizeLineBytes {
+		return ErrChunkLineTooLong
+	}
+	if numBytes <= 0 && buffered > maxChunkSizeLineBytes+len(tokens.CRLF) {
+		return ErrChunkLineTooLong
+	}
+	return nil
+}
+
+// parseFieldLine parses one header or trailer line into h, counting it
+// against the header limits.
+func (r *Request) parseFieldLine(h *headers.Headers, data []byte) (int, bool, error) {
+	numBytes, done, err := h.Parse(data)
+	if err != nil {
+		return 0, false, err
+	}
+
+	maxBytes := r.limits.MaxHeaderBytes
+	if numBytes <= 0 {
+		if maxBytes > 0 && r.headerBytes+len(data) > maxBytes+len(tokens.CRLF) {
+			return 0, false, ErrHeadersTooLarge
+		}
+		return 0, false, nil
+	}
+
+	r.headerBytes += numBytes
+	if maxBytes > 0 && r.headerBytes > maxBytes {
+		return 0, false, ErrHeadersTooLarge
+	}
+
+	if !done {
+		r.headerCount++
+		if r.limits.MaxHeaderCount > 0 && r.headerCount > r.limits.MaxHeaderCount {
+			return 0, false, ErrHeadersTooLarge
+		}
+	}
+
+	return numBytes, done, nil
+}
+
+func (r *Request) checkBodySize(size int) error {
+	if r.limits.MaxBodyBytes > 0 && size > r.limits.MaxBodyBytes {
+		return ErrBodyTooLarge
+	}
+	return nil
+}
@@ -6,9 +6,20 @@ import (
 	"go-http-server/internal/headers"
 	"go-http-server/internal/tokens"
 	"io"
+	"net/url"
 	"strconv"
 )
 
+// Supported values of RequestLine.HttpVersion.
+const (
+	HTTP10 = "1.0"
+	HTTP11 = "1.1"
+)
+
+// ErrVersionNotSupported is returned for a well-formed request line with an
+// HTTP version other than 1.0 or 1.1.
+var ErrVersionNotSupported = errors.New("request error: http version not supported")
+
 type RequestLine struct {
 	HttpVersion   string
 	RequestTarget string
@@ -21,14 +32,44 @@ const (
 	Initialized RequestState = iota
 	ParsingHeaders
 	ParsingBody
+	ParsingChunkSize
+	ParsingChunkData
+	ParsingChunkDataEnd
+	ParsingTrailers
 	Done
 )
 
 type Request struct {
 	RequestLine  RequestLine
 	RequestState RequestState
-	Headers      headers.Headers
-	Body         []byte
+	// URL is the parsed RequestLine.RequestTarget.
+	URL *url.URL
+	// Host is the authority the request is for: the host of an
+	// absolute-form or authority-form target, or else the Host header.
+	Host    string
+	Headers *headers.Headers
+	// Body streams the request body from the connection. Trailers are
+	// filled in once Body has been read to io.EOF.
+	Body     io.ReadCloser
+	Trailers *headers.Headers
+	Params   map[string]string
+
+	limits        Limits
+	headerBytes   int
+	headerCount   int
+	bodyRemaining int
+	bodyBytes     int
+	// pending holds decoded body bytes not yet returned by Body.
+	pending []byte
+
+	expectContinue bool
+	onContinue     func()
+}
+
+// Param returns the path parameter captured under name by a router, or ""
+// if there is none.
+func (r *Request) Param(name string) string {
+	return r.Params[name]
 }
 
 func (r *Request) parse(data []byte) (int, error) {
@@ -38,43 +79,111 @@ func (r *Request) parse(data []byte) (int, error) {
 		if err != nil {
 			return 0, err
 		}
+		if err := r.checkRequestLine(numBytes, len(data)); err != nil {
+			return 0, err
+		}
 		if numBytes <= 0 {
 			return 0, nil
 		}
+		u, err := parseRequestTarget(requestLine.Method, requestLine.RequestTarget)
+		if err != nil {
+			return 0, err
+		}
 		r.RequestLine = requestLine
+		r.URL = u
 		r.RequestState = ParsingHeaders
 		return numBytes, nil
 
 	case ParsingHeaders:
-		numBytes, done, err := r.Headers.Parse(data)
+		numBytes, done, err := r.parseFieldLine(r.Headers, data)
 		if err != nil {
 			return 0, err
 		}
 		if done {
-			contentLength := r.Headers.Get("content-length")
-			if contentLength == "" || contentLength == "0" {
+			if err := r.resolveHost(); err != nil {
+				return 0, err
+			}
+			chunked, contentLength, err := r.bodyFraming()
+			if err != nil {
+				return 0, err
+			}
+			switch {
+			case chunked:
+				r.RequestState = ParsingChunkSize
+			case contentLength == 0:
 				r.RequestState = Done
-			} else {
+			default:
+				r.bodyRemaining = contentLength
 				r.RequestState = ParsingBody
 			}
+			if err := r.checkExpect(); err != nil {
+				return 0, err
+			}
 		}
 		return numBytes, nil
 
 	case ParsingBody:
-		contentLength := r.Headers.Get("content-length")
-		if contentLength == "" || contentLength == "0" {
+		// Bytes past the declared length belong to the next pipelined request.
+		numBytes := min(len(data), r.bodyRemaining)
+		r.pending = append(r.pending, data[:numBytes]...)
+		r.bodyBytes += numBytes
+		r.bodyRemaining -= numBytes
+		if r.bodyRemaining == 0 {
 			r.RequestState = Done
-			return len(data), nil
 		}
-		r.Body = append(r.Body, data...)
-		contentLengthVal, err := strconv.Atoi(contentLength)
+		return numBytes, nil
+
+	case ParsingChunkSize:
+		chunkSize, numBytes, err := parseChunkSize(data)
+		if err != nil {
+			return 0, err
+		}
+		if err := checkChunkSizeLine(numBytes, len(data)); err != nil {
+			return 0, err
+		}
+		if numBytes <= 0 {
+			return 0, nil
+		}
+		if err := r.checkBodySize(r.bodyBytes + chunkSize); err != nil {
+			return 0, err
+		}
+		if chunkSize == 0 {
+			r.RequestState = ParsingTrailers
+		} else {
+			r.bodyRemaining = chunkSize
+			r.RequestState = ParsingChunkData
+		}
+		return numBytes, nil
+
+	case ParsingChunkData:
+		numBytes := min(len(data), r.bodyRemaining)
+		r.pending = append(r.pending, data[:numBytes]...)
+		r.bodyBytes += numBytes
+		r.bodyRemaining -= numBytes
+		if r.bodyRemaining == 0 {
+			r.RequestState = ParsingChunkDataEnd
+		}
+		return numBytes, nil
+
+	case ParsingChunkDataEnd:
+		if len(data) < len(tokens.CRLF) {
+			return 0, nil
+		}
+		if !bytes.HasPrefix(data, []byte(tokens.CRLF)) {
+			return 0, errors.New("request error: missing CRLF after chunk data")
+		}
+		r.RequestState = ParsingChunkSize
+		return len(tokens.CRLF), nil
+
+	case ParsingTrailers:
+		numBytes, done, err := r.parseFieldLine(r.Trailers, data)
 		if err != nil {
 			return 0, err
 		}
-		if len(r.Body) == contentLengthVal {
+		if done {
 			r.RequestState = Done
 		}
-		return len(data), nil
+		return numBytes, nil
 
 	case Done:
 		return 0, nil
@@ -85,50 +194,151 @@ func (r *Request) parse(data []byte) (int, error) {
 
 const BufferSize = 8
 
-func RequestFromReader(reader io.Reader) (*Request, error) {
-	buf := make([]byte, BufferSize)
-	readToIndex := 0
+// Reader reads consecutive requests from a single connection. Bytes read
+// past the end of one request stay buffered for the next one.
+type Reader struct {
+	reader      io.Reader
+	buf         []byte
+	readToIndex int
+
+	// Limits applies to every request read after it is set.
+	Limits Limits
+	// LenientHeaders unfolds obs-fold lines in headers and trailers
+	// instead of rejecting the request.
+	LenientHeaders bool
+}
+
+func NewReader(reader io.Reader) *Reader {
+	return &Reader{
+		reader: reader,
+		buf:    make([]byte, BufferSize),
+	}
+}
+
+// ReadRequest reads a whole request, buffering its body in memory. It
+// returns io.EOF if the connection was closed before any byte of a new
+// request arrived.
+func (r *Reader) ReadRequest() (*Request, error) {
+	req, err := r.ReadHeaders()
+	if err != nil {
+		return nil, err
+	}
+	body, err := req.ReadBody()
+	if err != nil {
+		return nil, err
+	}
+	req.Body = io.NopCloser(bytes.NewReader(body))
+	return req, nil
+}
+
+// ReadHeaders reads a request up to the end of its header section and
+// returns it with a Body that streams from the connection. Body must be
+// read to the end or closed before the next request can be read.
+func (r *Reader) ReadHeaders() (*Request, error) {
 	req := &Request{
 		RequestState: Initialized,
 		Headers:      headers.NewHeaders(),
+		Trailers:     headers.NewHeaders(),
+		limits:       r.Limits,
+	}
+	req.Headers.SetLenient(r.LenientHeaders)
+	req.Trailers.SetLenient(r.LenientHeaders)
+
+	err := r.readUntil(req, func() bool {
+		return req.RequestState != Initialized && req.RequestState != ParsingHeaders
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if req.RequestState == Done {
+		req.Body = NoBody
+	} else {
+		req.Body = &body{reader: r, req: req}
 	}
+	return req, nil
+}
 
-	for req.RequestState != Done {
-		if readToIndex >= len(buf) {
-			newBuf := make([]byte, len(buf)*2)
-			copy(newBuf, buf)
-			buf = newBuf
+func (r *Reader) readUntil(req *Request, done func() bool) error {
+	eof := false
+	for {
+		if err := r.parseBuffered(req, done); err != nil {
+			return err
 		}
 
-		n, err := reader.Read(buf[readToIndex:])
+		if done() {
+			return nil
+		}
 
-		if err != nil && !errors.Is(err, io.EOF) {
-			return nil, err
+		if eof {
+			if req.RequestState == Initialized && r.readToIndex == 0 {
+				return io.EOF
+			}
+			return errors.New("request error: unexpected end of request")
 		}
 
-		readToIndex += n
+		if r.readToIndex >= len(r.buf) {
+			newBuf := make([]byte, len(r.buf)*2)
+			copy(newBuf, r.buf)
+			r.buf = newBuf
+		}
 
-		for {
-			numBytes, err := req.parse(buf[:readToIndex])
+		n, err := r.reader.Read(r.buf[r.readToIndex:])
+		r.readToIndex += n
 
-			if err != nil {
-				return nil, err
+		if err != nil {
+			if !errors.Is(err, io.EOF) {
+				return err
 			}
+			eof = true
+		}
+	}
+}
 
-			if numBytes <= 0 {
-				break
-			}
+// Buffered returns the number of bytes read from the connection that have
+// not been consumed by a request yet.
+func (r *Reader) Buffered() int {
+	return r.readToIndex
+}
 
-			copy(buf, buf[numBytes:readToIndex])
-			readToIndex -= numBytes
+// TakeBuffered removes and returns the bytes read from the connection but
+// not parsed yet, such as the start of a pipelined request or of another
+// protocol after an upgrade.
+func (r *Reader) TakeBuffered() []byte {
+	b := make([]byte, r.readToIndex)
+	copy(b, r.buf[:r.readToIndex])
+	r.readToIndex = 0
+	return b
+}
 
-			if readToIndex <= 0 {
-				break
-			}
+func (r *Reader) parseBuffered(req *Request, done func() bool) error {
+	for r.readToIndex > 0 && !done() {
+		numBytes, err := req.parse(r.buf[:r.readToIndex])
+		if err != nil {
+			return err
 		}
+
+		if numBytes <= 0 {
+			return nil
+		}
+
+		copy(r.buf, r.buf[numBytes:r.readToIndex])
+		r.readToIndex -= numBytes
 	}
+	return nil
+}
 
-	return req, nil
+func RequestFromReader(reader io.Reader) (*Request, error) {
+	return NewReader(reader).ReadRequest()
+}
+
+// KeepAlive reports whether the client allows the connection to be reused
+// after this request. HTTP/1.0 clients have to ask for it explicitly.
+func (r *Request) KeepAlive() bool {
+	if r.RequestLine.HttpVersion == HTTP10 {
+		return r.Headers.HasToken("connection", "keep-alive")
+	}
+	return !r.Headers.HasToken("connection", "close")
 }
 
 func parseRequestLine(data []byte) (RequestLine, int, error) {
@@ -160,6 +370,101 @@ func parseRequestLine(data []byte) (RequestLine, int, error) {
 	}, len(line) + len(tokens.CRLF), nil
 }
 
+const maxChunkSizeDigits = 15
+
+func parseChunkSize(data []byte) (int, int, error) {
+	crlfIndex := bytes.Index(data, []byte(tokens.CRLF))
+	if crlfIndex == -1 {
+		return 0, 0, nil
+	}
+	line := data[:crlfIndex]
+
+	sizeEnd := 0
+	for sizeEnd < len(line) && isHexDigit(line[sizeEnd]) {
+		sizeEnd++
+	}
+	if sizeEnd == 0 {
+		return 0, 0, errors.New("request error: missing chunk size")
+	}
+	if sizeEnd > maxChunkSizeDigits {
+		return 0, 0, errors.New("request error: chunk size too large")
+	}
+
+	if err := validateChunkExtensions(line[sizeEnd:]); err != nil {
+		return 0, 0, err
+	}
+
+	chunkSize, err := strconv.ParseInt(string(line[:sizeEnd]), 16, 64)
+	if err != nil {
+		return 0, 0, err
+	}
+
+	return int(chunkSize), len(line) + len(tokens.CRLF), nil
+}
+
+// validateChunkExtensions checks the chunk-ext grammar from RFC 9112 7.1.1.
+// Extensions are accepted and ignored.
+func validateChunkExtensions(ext []byte) error {
+	for len(ext) > 0 {
+		ext = bytes.TrimLeft(ext, " \t")
+		if len(ext) == 0 {
+			return nil
+		}
+		if ext[0] != ';' {
+			return errors.New("request error: invalid chunk extension")
+		}
+		ext = bytes.TrimLeft(ext[1:], " \t")
+
+		nameEnd := 0
+		for nameEnd < len(ext) && headers.IsTokenChar(ext[nameEnd]) {
+			nameEnd++
+		}
+		if nameEnd == 0 {
+			return errors.New("request error: invalid chunk extension name")
+		}
+		ext = bytes.TrimLeft(ext[nameEnd:], " \t")
+
+		if len(ext) == 0 || ext[0] != '=' {
+			continue
+		}
+		ext = bytes.TrimLeft(ext[1:], " \t")
+
+		valueEnd, err := chunkExtensionValueEnd(ext)
+		if err != nil {
+			return err
+		}
+		ext = ext[valueEnd:]
+	}
+	return nil
+}
+
+func chunkExtensionValueEnd(ext []byte) (int, error) {
+	if len(ext) > 0 && ext[0] == '"' {
+		for i := 1; i < len(ext); i++ {
+			switch ext[i] {
+			case '\\':
+				i++
+			case '"':
+				return i + 1, nil
+			}
+		}
+		return 0, errors.New("request error: unterminated chunk extension value")
+	}
+
+	valueEnd := 0
+	for valueEnd < len(ext) && headers.IsTokenChar(ext[valueEnd]) {
+		valueEnd++
+	}
+	if valueEnd == 0 {
+		return 0, errors.New("request error: invalid chunk extension value")
+	}
+	return valueEnd, nil
+}
+
+func isHexDigit(b byte) bool {
+	return ('0' <= b && b <= '9') || ('a' <= b && b <= 'f') || ('A' <= b && b <= 'F')
+}
+
 func validateMethod(method []byte) error {
 	if len(method) == 0 {
 		return errors.New("request error: request line empty")
@@ -179,9 +484,18 @@ func validateHttpVersion(httpVersion []byte) error {
 		return errors.New("request error: invalid http name")
 	}
 
-	if !bytes.Equal(httpVersion[len(tokens.HTTPVersionPrefix):], []byte("1.1")) {
+	version := httpVersion[len(tokens.HTTPVersionPrefix):]
+	if len(version) != 3 || !isDigit(version[0]) || version[1] != '.' || !isDigit(version[2]) {
 		return errors.New("request error: invalid http version")
 	}
 
+	if string(version) != HTTP10 && string(version) != HTTP11 {
+		return ErrVersionNotSupported
+	}
+
 	return nil
 }
+
+func isDigit(b byte) bool {
+	return '0' <= b && b <= '9'
+}
@@ -31,6 +31,12 @@ func (cr *chunkReader) Read(p []byte) (n int, err error) {
 	return n, nil
 }
 
+func readBody(t *testing.T, r *Request) string {
+	body, err := r.ReadBody()
+	require.NoError(t, err)
+	return string(body)
+}
+
 func TestRequestLineParse(t *testing.T) {
 	// Test: Good GET Request line
 	r, err := RequestFromReader(strings.NewReader("GET / HTTP/1.1\r\nHost: localhost:8080\r\nUser-Agent: curl/7.81.0\r\nAccept: */*\r\n\r\n"))
@@ -102,13 +108,13 @@ func TestHeadersParse(t *testing.T) {
 	r, err := RequestFromReader(reader)
 	require.NoError(t, err)
 	require.NotNil(t, r)
-	assert.Equal(t, "localhost:8080", r.Headers["host"])
-	assert.Equal(t, "curl/7.81.0", r.Headers["user-agent"])
-	assert.Equal(t, "*/*", r.Headers["accept"])
+	assert.Equal(t, "localhost:8080", r.Headers.Get("host"))
+	assert.Equal(t, "curl/7.81.0", r.Headers.Get("user-agent"))
+	assert.Equal(t, "*/*", r.Headers.Get("accept"))
 
 	// Test: Empty Headers
 	reader = &chunkReader{
-		data:            "GET / HTTP/1.1\r\n\r\n",
+		data:            "GET / HTTP/1.0\r\n\r\n",
 		numBytesPerRead: 3,
 	}
 	_, err = RequestFromReader(reader)
@@ -124,12 +130,12 @@ func TestHeadersParse(t *testing.T) {
 
 	// Test: Duplicate Headers
 	reader = &chunkReader{
-		data:            "GET / HTTP/1.1\r\nSet-Person: alice\r\nSet-Person: bob\r\n\r\n",
+		data:            "GET / HTTP/1.1\r\nHost: localhost:8080\r\nSet-Person: alice\r\nSet-Person: bob\r\n\r\n",
 		numBytesPerRead: 3,
 	}
 	r, err = RequestFromReader(reader)
 	require.NoError(t, err)
-	assert.Equal(t, "alice, bob", r.Headers["set-person"])
+	assert.Equal(t, "alice, bob", r.Headers.Get("set-person"))
 
 	// Test: Case Insensitive Headers
 	reader = &chunkReader{
@@ -138,7 +144,7 @@ func TestHeadersParse(t *testing.T) {
 	}
 	r, err = RequestFromReader(reader)
 	require.NoError(t, err)
-	assert.Equal(t, "localhost:8080", r.Headers["host"])
+	assert.Equal(t, "localhost:8080", r.Headers.Get("host"))
 
 	// Test: Missing End of Headers
 	reader = &chunkReader{
@@ -162,7 +168,7 @@ func TestBodyParse(t *testing.T) {
 	r, err := RequestFromReader(reader)
 	require.NoError(t, err)
 	require.NotNil(t, r)
-	assert.Equal(t, "hello world!\n", string(r.Body))
+	assert.Equal(t, "hello world!\n", readBody(t, r))
 
 	// Test: Empty Body, 0 reported content length
 	reader = &chunkReader{
@@ -175,7 +181,7 @@ func TestBodyParse(t *testing.T) {
 	r, err = RequestFromReader(reader)
 	require.NoError(t, err)
 	require.NotNil(t, r)
-	assert.Equal(t, "", string(r.Body))
+	assert.Equal(t, "", readBody(t, r))
 
 	// Test: Empty Body, no reported content length
 	reader = &chunkReader{
@@ -187,7 +193,7 @@ func TestBodyParse(t *testing.T) {
 	r, err = RequestFromReader(reader)
 	require.NoError(t, err)
 	require.NotNil(t, r)
-	assert.Equal(t, "", string(r.Body))
+	assert.Equal(t, "", readBody(t, r))
 
 	// Test: Body shorter than reported content length
 	reader = &chunkReader{
@@ -213,3 +219,615 @@ func TestBodyParse(t *testing.T) {
 	require.NoError(t, err)
 	require.NotNil(t, r)
 }
+
+func TestChunkedBodyParse(t *testing.T) {
+	// Test: Standard chunked body
+	reader := &chunkReader{
+		data: "POST /submit HTTP/1.1\r\n" +
+			"Host: localhost:8080\r\n" +
+			"Transfer-Encoding: chunked\r\n" +
+			"\r\n" +
+			"6\r\n" +
+			"hello \r\n" +
+			"7\r\n" +
+			"world!\n\r\n" +
+			"0\r\n" +
+			"\r\n",
+		numBytesPerRead: 3,
+	}
+	r, err := RequestFromReader(reader)
+	require.NoError(t, err)
+	require.NotNil(t, r)
+	assert.Equal(t, "hello world!\n", readBody(t, r))
+
+	// Test: Hex chunk sizes and chunk extensions
+	reader = &chunkReader{
+		data: "POST /submit HTTP/1.1\r\n" +
+			"Host: localhost:8080\r\n" +
+			"Transfer-Encoding: chunked\r\n" +
+			"\r\n" +
+			"A;name=value\r\n" +
+			"0123456789\r\n" +
+			"1 ; a ; b=\"quoted;\\\"value\"\r\n" +
+			"!\r\n" +
+			"0;last\r\n" +
+			"\r\n",
+		numBytesPerRead: 5,
+	}
+	r, err = RequestFromReader(reader)
+	require.NoError(t, err)
+	require.NotNil(t, r)
+	assert.Equal(t, "0123456789!", readBody(t, r))
+
+	// Test: Trailers
+	reader = &chunkReader{
+		data: "POST /submit HTTP/1.1\r\n" +
+			"Host: localhost:8080\r\n" +
+			"Transfer-Encoding: chunked\r\n" +
+			"Trailer: X-Checksum\r\n" +
+			"\r\n" +
+			"5\r\n" +
+			"hello\r\n" +
+			"0\r\n" +
+			"X-Checksum: abc123\r\n" +
+			"\r\n",
+		numBytesPerRead: 1,
+	}
+	r, err = RequestFromReader(reader)
+	require.NoError(t, err)
+	require.NotNil(t, r)
+	assert.Equal(t, "hello", readBody(t, r))
+	assert.Equal(t, "abc123", r.Trailers.Get("x-checksum"))
+	assert.Empty(t, r.Headers.Get("x-checksum"))
+
+	// Test: Codings other than chunked cannot be decoded
+	reader = &chunkReader{
+		data: "POST /submit HTTP/1.1\r\n" +
+			"Host: localhost:8080\r\n" +
+			"Transfer-Encoding: gzip, Chunked\r\n" +
+			"\r\n" +
+			"3\r\n" +
+			"abc\r\n" +
+			"0\r\n" +
+			"\r\n",
+		numBytesPerRead: 4,
+	}
+	_, err = RequestFromReader(reader)
+	assert.ErrorIs(t, err, ErrTransferCodingNotImplemented)
+
+	// Test: Invalid chunk size
+	reader = &chunkReader{
+		data: "POST /submit HTTP/1.1\r\n" +
+			"Host: localhost:8080\r\n" +
+			"Transfer-Encoding: chunked\r\n" +
+			"\r\n" +
+			"zz\r\n" +
+			"hello\r\n" +
+			"0\r\n" +
+			"\r\n",
+		numBytesPerRead: 3,
+	}
+	_, err = RequestFromReader(reader)
+	require.Error(t, err)
+
+	// Test: Invalid chunk extension
+	reader = &chunkReader{
+		data: "POST /submit HTTP/1.1\r\n" +
+			"Host: localhost:8080\r\n" +
+			"Transfer-Encoding: chunked\r\n" +
+			"\r\n" +
+			"5 garbage\r\n" +
+			"hello\r\n" +
+			"0\r\n" +
+			"\r\n",
+		numBytesPerRead: 3,
+	}
+	_, err = RequestFromReader(reader)
+	require.Error(t, err)
+
+	// Test: Chunk data longer than chunk size
+	reader = &chunkReader{
+		data: "POST /submit HTTP/1.1\r\n" +
+			"Host: localhost:8080\r\n" +
+			"Transfer-Encoding: chunked\r\n" +
+			"\r\n" +
+			"3\r\n" +
+			"hello\r\n" +
+			"0\r\n" +
+			"\r\n",
+		numBytesPerRead: 3,
+	}
+	_, err = RequestFromReader(reader)
+	require.Error(t, err)
+
+	// Test: Missing last chunk
+	reader = &chunkReader{
+		data: "POST /submit HTTP/1.1\r\n" +
+			"Host: localhost:8080\r\n" +
+			"Transfer-Encoding: chunked\r\n" +
+			"\r\n" +
+			"5\r\n" +
+			"hello\r\n",
+		numBytesPerRead: 3,
+	}
+	_, err = RequestFromReader(reader)
+	require.Error(t, err)
+}
+
+func TestReaderMultipleRequests(t *testing.T) {
+	// Test: Consecutive requests on one connection
+	reader := NewReader(&chunkReader{
+		data: "GET /first HTTP/1.1\r\n" +
+			"Host: localhost:8080\r\n" +
+			"\r\n" +
+			"GET /second HTTP/1.1\r\n" +
+			"Host: localhost:8080\r\n" +
+			"Connection: close\r\n" +
+			"\r\n",
+		numBytesPerRead: 7,
+	})
+	r, err := reader.ReadRequest()
+	require.NoError(t, err)
+	assert.Equal(t, "/first", r.RequestLine.RequestTarget)
+	assert.True(t, r.KeepAlive())
+
+	r, err = reader.ReadRequest()
+	require.NoError(t, err)
+	assert.Equal(t, "/second", r.RequestLine.RequestTarget)
+	assert.False(t, r.KeepAlive())
+
+	// Test: Clean close between requests
+	_, err = reader.ReadRequest()
+	assert.ErrorIs(t, err, io.EOF)
+
+	// Test: Close in the middle of a request
+	reader = NewReader(&chunkReader{
+		data:            "GET / HTTP/1.1\r\nHost: localhost:8080\r\n",
+		numBytesPerRead: 3,
+	})
+	_, err = reader.ReadRequest()
+	require.Error(t, err)
+	assert.NotErrorIs(t, err, io.EOF)
+}
+
+func TestPipelinedRequests(t *testing.T) {
+	// Test: Surplus bytes after a body go to the next request
+	reader := NewReader(strings.NewReader(
+		"POST /one HTTP/1.1\r\n" +
+			"Host: localhost:8080\r\n" +
+			"Content-Length: 5\r\n" +
+			"\r\n" +
+			"hello" +
+			"POST /two HTTP/1.1\r\n" +
+			"Host: localhost:8080\r\n" +
+			"Transfer-Encoding: chunked\r\n" +
+			"\r\n" +
+			"5\r\n" +
+			"world\r\n" +
+			"0\r\n" +
+			"\r\n" +
+			"GET /three HTTP/1.1\r\n" +
+			"Host: localhost:8080\r\n" +
+			"\r\n",
+	))
+	r, err := reader.ReadRequest()
+	require.NoError(t, err)
+	assert.Equal(t, "/one", r.RequestLine.RequestTarget)
+	assert.Equal(t, "hello", readBody(t, r))
+
+	r, err = reader.ReadRequest()
+	require.NoError(t, err)
+	assert.Equal(t, "/two", r.RequestLine.RequestTarget)
+	assert.Equal(t, "world", readBody(t, r))
+
+	r, err = reader.ReadRequest()
+	require.NoError(t, err)
+	assert.Equal(t, "/three", r.RequestLine.RequestTarget)
+	assert.Empty(t, readBody(t, r))
+
+	_, err = reader.ReadRequest()
+	assert.ErrorIs(t, err, io.EOF)
+
+	// Test: Pipelined requests split across small reads
+	reader = NewReader(&chunkReader{
+		data: "POST /one HTTP/1.1\r\n" +
+			"Host: localhost:8080\r\n" +
+			"Content-Length: 3\r\n" +
+			"\r\n" +
+			"abc" +
+			"GET /two HTTP/1.1\r\n" +
+			"Host: localhost:8080\r\n" +
+			"\r\n",
+		numBytesPerRead: 4,
+	})
+	r, err = reader.ReadRequest()
+	require.NoError(t, err)
+	assert.Equal(t, "abc", readBody(t, r))
+
+	r, err = reader.ReadRequest()
+	require.NoError(t, err)
+	assert.Equal(t, "/two", r.RequestLine.RequestTarget)
+}
+
+func TestRequestLimits(t *testing.T) {
+	limits := Limits{
+		MaxRequestLineBytes: 20,
+		MaxHeaderBytes:      40,
+		MaxHeaderCount:      2,
+		MaxBodyBytes:        5,
+	}
+	readRequest := func(data string) (*Request, error) {
+		reader := NewReader(&chunkReader{data: data, numBytesPerRead: 3})
+		reader.Limits = limits
+		return reader.ReadRequest()
+	}
+
+	// Test: Within every limit
+	r, err := readRequest("POST /ok HTTP/1.1\r\nHost: a\r\nContent-Length: 5\r\n\r\nhello")
+	require.NoError(t, err)
+	assert.Equal(t, "hello", readBody(t, r))
+
+	// Test: Request line too long
+	_, err = readRequest("GET /a-very-long-target HTTP/1.1\r\n\r\n")
+	assert.ErrorIs(t, err, ErrRequestLineTooLong)
+
+	// Test: Request line too long without CRLF
+	_, err = readRequest("GET /a-very-long-target-that-never-ends")
+	assert.ErrorIs(t, err, ErrRequestLineTooLong)
+
+	// Test: Header section too large
+	_, err = readRequest("GET / HTTP/1.1\r\nX-Big: " + strings.Repeat("a", 40) + "\r\n\r\n")
+	assert.ErrorIs(t, err, ErrHeadersTooLarge)
+
+	// Test: Too many header fields
+	_, err = readRequest("GET / HTTP/1.1\r\nA: 1\r\nB: 2\r\nC: 3\r\n\r\n")
+	assert.ErrorIs(t, err, ErrHeadersTooLarge)
+
+	// Test: Declared body too large
+	_, err = readRequest("POST / HTTP/1.1\r\nHost: a\r\nContent-Length: 6\r\n\r\nhello!")
+	assert.ErrorIs(t, err, ErrBodyTooLarge)
+
+	// Test: Chunked body too large
+	_, err = readRequest("POST / HTTP/1.1\r\nHost: a\r\nTransfer-Encoding: chunked\r\n\r\n3\r\nabc\r\n3\r\ndef\r\n0\r\n\r\n")
+	assert.ErrorIs(t, err, ErrBodyTooLarge)
+
+	// Test: Chunk size line without CRLF
+	_, err = readRequest("POST / HTTP/1.1\r\nHost: a\r\nTransfer-Encoding: chunked\r\n\r\n5;x=" + strings.Repeat("a", 5000))
+	assert.ErrorIs(t, err, ErrChunkLineTooLong)
+
+	// Test: Chunk size line with long extensions
+	_, err = readRequest("POST / HTTP/1.1\r\nHost: a\r\nTransfer-Encoding: chunked\r\n\r\n5;x=" + strings.Repeat("a", 5000) + "\r\nhello\r\n0\r\n\r\n")
+	assert.ErrorIs(t, err, ErrChunkLineTooLong)
+
+	// Test: Body limit overridden after the headers are read
+	reader := NewReader(strings.NewReader("POST / HTTP/1.1\r\nHost: a\r\nContent-Length: 6\r\n\r\nhello!"))
+	reader.Limits = limits
+	r, err = reader.ReadHeaders()
+	require.NoError(t, err)
+	r.SetMaxBodyBytes(6)
+	assert.Equal(t, "hello!", readBody(t, r))
+}
+
+func TestStreamingBody(t *testing.T) {
+	// Test: Body is read from the connection as the handler reads it
+	reader := NewReader(&chunkReader{
+		data: "POST /upload HTTP/1.1\r\n" +
+			"Host: localhost:8080\r\n" +
+			"Transfer-Encoding: chunked\r\n" +
+			"\r\n" +
+			"5\r\nhello\r\n" +
+			"6\r\n world\r\n" +
+			"0\r\n" +
+			"X-Sum: 42\r\n" +
+			"\r\n" +
+			"GET /next HTTP/1.1\r\n" +
+			"Host: localhost:8080\r\n" +
+			"\r\n",
+		numBytesPerRead: 4,
+	})
+	r, err := reader.ReadHeaders()
+	require.NoError(t, err)
+	assert.NotEqual(t, Done, r.RequestState)
+
+	buf := make([]byte, 3)
+	n, err := io.ReadFull(r.Body, buf)
+	require.NoError(t, err)
+	assert.Equal(t, "hel", string(buf[:n]))
+	assert.Empty(t, r.Trailers.Get("x-sum"))
+
+	rest, err := io.ReadAll(r.Body)
+	require.NoError(t, err)
+	assert.Equal(t, "lo world", string(rest))
+	assert.Equal(t, "42", r.Trailers.Get("x-sum"))
+	require.NoError(t, r.Body.Close())
+
+	r, err = reader.ReadHeaders()
+	require.NoError(t, err)
+	assert.Equal(t, "/next", r.RequestLine.RequestTarget)
+	assert.Equal(t, NoBody, r.Body)
+
+	// Test: Close discards an unread body
+	reader = NewReader(strings.NewReader(
+		"POST /one HTTP/1.1\r\nHost: a\r\nContent-Length: 5\r\n\r\nhello" +
+			"GET /two HTTP/1.1\r\nHost: a\r\n\r\n",
+	))
+	r, err = reader.ReadHeaders()
+	require.NoError(t, err)
+	require.NoError(t, r.Body.Close())
+	_, err = r.Body.Read(buf)
+	require.Error(t, err)
+
+	r, err = reader.ReadHeaders()
+	require.NoError(t, err)
+	assert.Equal(t, "/two", r.RequestLine.RequestTarget)
+
+	// Test: Body errors surface from Read
+	reader = NewReader(strings.NewReader("POST / HTTP/1.1\r\nHost: a\r\nContent-Length: 10\r\n\r\nshort"))
+	r, err = reader.ReadHeaders()
+	require.NoError(t, err)
+	_, err = io.ReadAll(r.Body)
+	require.Error(t, err)
+	require.Error(t, r.Body.Close())
+}
+
+func TestHttpVersion(t *testing.T) {
+	// Test: HTTP/1.0 closes by default
+	r, err := RequestFromReader(strings.NewReader("GET / HTTP/1.0\r\n\r\n"))
+	require.NoError(t, err)
+	assert.Equal(t, HTTP10, r.RequestLine.HttpVersion)
+	assert.False(t, r.KeepAlive())
+
+	// Test: HTTP/1.0 keep-alive
+	r, err = RequestFromReader(strings.NewReader("GET / HTTP/1.0\r\nConnection: Keep-Alive\r\n\r\n"))
+	require.NoError(t, err)
+	assert.True(t, r.KeepAlive())
+
+	// Test: HTTP/1.1 stays alive by default
+	r, err = RequestFromReader(strings.NewReader("GET / HTTP/1.1\r\nHost: localhost:8080\r\n\r\n"))
+	require.NoError(t, err)
+	assert.Equal(t, HTTP11, r.RequestLine.HttpVersion)
+	assert.True(t, r.KeepAlive())
+
+	// Test: Well-formed but unsupported versions
+	_, err = RequestFromReader(strings.NewReader("GET / HTTP/2.0\r\n\r\n"))
+	assert.ErrorIs(t, err, ErrVersionNotSupported)
+	_, err = RequestFromReader(strings.NewReader("GET / HTTP/0.9\r\n\r\n"))
+	assert.ErrorIs(t, err, ErrVersionNotSupported)
+
+	// Test: Malformed versions
+	_, err = RequestFromReader(strings.NewReader("GET / HTTP/1\r\n\r\n"))
+	require.Error(t, err)
+	assert.NotErrorIs(t, err, ErrVersionNotSupported)
+	_, err = RequestFromReader(strings.NewReader("GET / HTTP/1.10\r\n\r\n"))
+	require.Error(t, err)
+	assert.NotErrorIs(t, err, ErrVersionNotSupported)
+}
+
+func TestRequestTarget(t *testing.T) {
+	// Test: Origin-form with query
+	r, err := RequestFromReader(strings.NewReader("GET /a%20b/c?x=1&y=2&x=3 HTTP/1.1\r\nHost: localhost:8080\r\n\r\n"))
+	require.NoError(t, err)
+	assert.Equal(t, "/a b/c", r.Path())
+	assert.Equal(t, "/a%20b/c", r.RawPath())
+	assert.Equal(t, []string{"1", "3"}, r.Query()["x"])
+	assert.Equal(t, "2", r.Query().Get("y"))
+
+	// Test: Origin-form without query
+	r, err = RequestFromReader(strings.NewReader("GET /video HTTP/1.1\r\nHost: localhost:8080\r\n\r\n"))
+	require.NoError(t, err)
+	assert.Equal(t, "/video", r.Path())
+	assert.Empty(t, r.Query())
+
+	// Test: Absolute-form
+	r, err = RequestFromReader(strings.NewReader("GET http://example.com:8080/path?q=go HTTP/1.1\r\nHost: localhost:8080\r\n\r\n"))
+	require.NoError(t, err)
+	assert.Equal(t, "example.com:8080", r.URL.Host)
+	assert.Equal(t, "/path", r.Path())
+	assert.Equal(t, "go", r.Query().Get("q"))
+
+	// Test: Absolute-form without a path
+	r, err = RequestFromReader(strings.NewReader("GET http://example.com HTTP/1.1\r\nHost: localhost:8080\r\n\r\n"))
+	require.NoError(t, err)
+	assert.Equal(t, "/", r.Path())
+
+	// Test: Authority-form for CONNECT
+	r, err = RequestFromReader(strings.NewReader("CONNECT example.com:443 HTTP/1.1\r\nHost: localhost:8080\r\n\r\n"))
+	require.NoError(t, err)
+	assert.Equal(t, "example.com:443", r.URL.Host)
+
+	// Test: Asterisk-form for OPTIONS
+	r, err = RequestFromReader(strings.NewReader("OPTIONS * HTTP/1.1\r\nHost: localhost:8080\r\n\r\n"))
+	require.NoError(t, err)
+	assert.Equal(t, "*", r.Path())
+
+	// Test: Invalid targets
+	for _, line := range []string{
+		"GET /a#frag HTTP/1.1",
+		"GET /a%zz HTTP/1.1",
+		"GET /a?x=%zz HTTP/1.1",
+		"GET a/b HTTP/1.1",
+		"GET * HTTP/1.1",
+		"GET example.com:443 HTTP/1.1",
+		"CONNECT /path HTTP/1.1",
+		"CONNECT example.com HTTP/1.1",
+		"CONNECT example.com:http HTTP/1.1",
+		"GET http:///path HTTP/1.1",
+	} {
+		_, err = RequestFromReader(strings.NewReader(line + "\r\nHost: localhost:8080\r\n\r\n"))
+		assert.Error(t, err, line)
+	}
+}
+
+func TestHost(t *testing.T) {
+	// Test: Host header
+	r, err := RequestFromReader(strings.NewReader("GET / HTTP/1.1\r\nHost: example.com:8080\r\n\r\n"))
+	require.NoError(t, err)
+	assert.Equal(t, "example.com:8080", r.Host)
+
+	// Test: Absolute-form target wins over the Host header
+	r, err = RequestFromReader(strings.NewReader("GET http://example.com/ HTTP/1.1\r\nHost: other.org\r\n\r\n"))
+	require.NoError(t, err)
+	assert.Equal(t, "example.com", r.Host)
+
+	// Test: Empty Host is allowed
+	r, err = RequestFromReader(strings.NewReader("GET / HTTP/1.1\r\nHost:\r\n\r\n"))
+	require.NoError(t, err)
+	assert.Empty(t, r.Host)
+
+	// Test: HTTP/1.0 does not need a Host
+	r, err = RequestFromReader(strings.NewReader("GET / HTTP/1.0\r\n\r\n"))
+	require.NoError(t, err)
+	assert.Empty(t, r.Host)
+
+	// Test: Missing Host
+	_, err = RequestFromReader(strings.NewReader("GET / HTTP/1.1\r\n\r\n"))
+	require.Error(t, err)
+
+	// Test: Duplicate Host
+	_, err = RequestFromReader(strings.NewReader("GET / HTTP/1.1\r\nHost: a.com\r\nHost: b.com\r\n\r\n"))
+	require.Error(t, err)
+
+	// Test: Invalid Host
+	for _, host := range []string{"a b", "a/b", "user@a.com", "a.com:port", "[::1"} {
+		_, err = RequestFromReader(strings.NewReader("GET / HTTP/1.1\r\nHost: " + host + "\r\n\r\n"))
+		assert.Error(t, err, host)
+	}
+}
+
+func TestObsFold(t *testing.T) {
+	data := "GET / HTTP/1.1\r\nHost: localhost:8080\r\nX-Long: first\r\n second\r\n\r\n"
+
+	// Test: Rejected by default
+	_, err := RequestFromReader(strings.NewReader(data))
+	require.Error(t, err)
+
+	// Test: Unfolded by a lenient reader
+	reader := NewReader(strings.NewReader(data))
+	reader.LenientHeaders = true
+	r, err := reader.ReadRequest()
+	require.NoError(t, err)
+	assert.Equal(t, "first second", r.Headers.Get("x-long"))
+
+	// Test: Whitespace right after the request line
+	reader = NewReader(strings.NewReader("GET / HTTP/1.1\r\n Host: localhost:8080\r\n\r\n"))
+	reader.LenientHeaders = true
+	_, err = reader.ReadRequest()
+	require.Error(t, err)
+
+	// Test: Whitespace before the first trailer field
+	reader = NewReader(strings.NewReader("POST / HTTP/1.1\r\nHost: localhost:8080\r\nTransfer-Encoding: chunked\r\n\r\n" +
+		"5\r\nhello\r\n0\r\n X-Evil: 1\r\n\r\n"))
+	reader.LenientHeaders = true
+	_, err = reader.ReadRequest()
+	require.Error(t, err)
+}
+
+func TestBodyFraming(t *testing.T) {
+	readRequest := func(head string, body string) (*Request, error) {
+		return RequestFromReader(strings.NewReader("POST / HTTP/1.1\r\nHost: localhost:8080\r\n" + head + "\r\n" + body))
+	}
+
+	// Test: Repeated identical Content-Length
+	r, err := readRequest("Content-Length: 5\r\nContent-Length: 5\r\n", "hello")
+	require.NoError(t, err)
+	assert.Equal(t, "hello", readBody(t, r))
+
+	// Test: Identical Content-Length list
+	r, err = readRequest("Content-Length: 5, 5\r\n", "hello")
+	require.NoError(t, err)
+	assert.Equal(t, "hello", readBody(t, r))
+
+	// Test: Transfer-Encoding split over field lines
+	r, err = readRequest("Transfer-Encoding: \r\nTransfer-Encoding: chunked\r\n", "5\r\nhello\r\n0\r\n\r\n")
+	require.NoError(t, err)
+	assert.Equal(t, "hello", readBody(t, r))
+
+	// Test: Both Transfer-Encoding and Content-Length
+	_, err = readRequest("Content-Length: 5\r\nTransfer-Encoding: chunked\r\n", "5\r\nhello\r\n0\r\n\r\n")
+	assert.ErrorIs(t, err, ErrBadFraming)
+
+	// Test: Invalid Content-Length values
+	for _, head := range []string{
+		"Content-Length: 5\r\nContent-Length: 7\r\n",
+		"Content-Length: 5, 7\r\n",
+		"Content-Length: -1\r\n",
+		"Content-Length: +5\r\n",
+		"Content-Length: 0x5\r\n",
+		"Content-Length: 5 5\r\n",
+		"Content-Length:\r\n",
+		"Content-Length: 5,\r\n",
+		"Content-Length: 99999999999999999999\r\n",
+	} {
+		_, err = readRequest(head, "hello")
+		assert.ErrorIs(t, err, ErrBadFraming, head)
+	}
+
+	// Test: Chunked must be the final coding, applied once
+	for _, head := range []string{
+		"Transfer-Encoding: chunked, gzip\r\n",
+		"Transfer-Encoding: chunked\r\nTransfer-Encoding: gzip\r\n",
+		"Transfer-Encoding: chunked, chunked\r\n",
+		"Transfer-Encoding: identity\r\n",
+		"Transfer-Encoding:\r\n",
+	} {
+		_, err = readRequest(head, "5\r\nhello\r\n0\r\n\r\n")
+		assert.ErrorIs(t, err, ErrBadFraming, head)
+	}
+
+	// Test: Transfer-Encoding in HTTP/1.0
+	_, err = RequestFromReader(strings.NewReader("POST / HTTP/1.0\r\nTransfer-Encoding: chunked\r\n\r\n0\r\n\r\n"))
+	assert.ErrorIs(t, err, ErrBadFraming)
+}
+
+func TestExpectContinue(t *testing.T) {
+	// Test: Continue is sent once, before the body is read
+	reader := NewReader(strings.NewReader("POST / HTTP/1.1\r\nHost: a\r\nExpect: 100-Continue\r\nContent-Length: 5\r\n\r\nhello"))
+	r, err := reader.ReadHeaders()
+	require.NoError(t, err)
+	assert.True(t, r.ExpectsContinue())
+	calls := 0
+	r.OnContinue(func() { calls++ })
+	assert.Equal(t, 0, calls)
+	assert.Equal(t, "hello", readBody(t, r))
+	assert.Equal(t, 1, calls)
+	assert.False(t, r.ExpectsContinue())
+
+	// Test: Continue is not sent when the body goes over the limit
+	reader = NewReader(strings.NewReader("POST / HTTP/1.1\r\nHost: a\r\nExpect: 100-continue\r\nContent-Length: 5\r\n\r\nhello"))
+	reader.Limits = Limits{MaxBodyBytes: 4}
+	r, err = reader.ReadHeaders()
+	require.NoError(t, err)
+	calls = 0
+	r.OnContinue(func() { calls++ })
+	_, err = io.ReadAll(r.Body)
+	assert.ErrorIs(t, err, ErrBodyTooLarge)
+	assert.Equal(t, 0, calls)
+	assert.True(t, r.ExpectsContinue())
+
+	// Test: Nothing to wait for without a body
+	r, err = RequestFromReader(strings.NewReader("POST / HTTP/1.1\r\nHost: a\r\nExpect: 100-continue\r\n\r\n"))
+	require.NoError(t, err)
+	assert.False(t, r.ExpectsContinue())
+
+	// Test: HTTP/1.0 expectation is ignored
+	reader = NewReader(strings.NewReader("POST / HTTP/1.0\r\nExpect: 100-continue\r\nContent-Length: 5\r\n\r\nhello"))
+	r, err = reader.ReadHeaders()
+	require.NoError(t, err)
+	assert.False(t, r.ExpectsContinue())
+
+	// Test: Unknown expectations
+	_, err = RequestFromReader(strings.NewReader("POST / HTTP/1.1\r\nHost: a\r\nExpect: 200-ok\r\nContent-Length: 0\r\n\r\n"))
+	assert.ErrorIs(t, err, ErrExpectationFailed)
+	_, err = RequestFromReader(strings.NewReader("POST / HTTP/1.1\r\nHost: a\r\nExpect: 100-continue, x\r\nContent-Length: 0\r\n\r\n"))
+	assert.ErrorIs(t, err, ErrExpectationFailed)
+}
+
+func TestTakeBuffered(t *testing.T) {
+	// Test: Bytes after the request are handed over
+	reader := NewReader(strings.NewReader("GET /chat HTTP/1.1\r\nHost: a\r\nUpgrade: websocket\r\n\r\n\x81\x05hello"))
+	_, err := reader.ReadHeaders()
+	require.NoError(t, err)
+	assert.Equal(t, "\x81\x05hello", string(reader.TakeBuffered()))
+	assert.Equal(t, 0, reader.Buffered())
+	assert.Empty(t, reader.TakeBuffered())
+}
@@ -0,0 +1,107 @@
+package request
+
+import (
+	"errors"
+	"net"
+	"net/url"
+	"strconv"
+	"strings"
+)
+
+// parseRequestTarget parses the four request-target forms from RFC 9112
+// 3.2. Origin-form and absolute-form targets fill in Path and RawQuery,
+// authority-form (CONNECT only) fills in Host, and asterisk-form (OPTIONS
+// only) has the Path "*".
+func parseRequestTarget(method string, target string) (*url.URL, error) {
+	if strings.Contains(target, "#") {
+		return nil, errors.New("request error: fragment in request target")
+	}
+
+	if method == "CONNECT" {
+		host, port, err := net.SplitHostPort(target)
+		if err != nil || host == "" {
+			return nil, errors.New("request error: CONNECT target must be host:port")
+		}
+		if _, err := strconv.ParseUint(port, 10, 16); err != nil {
+			return nil, errors.New("request error: invalid port in request target")
+		}
+		return &url.URL{Host: target}, nil
+	}
+
+	if target == "*" {
+		if method != "OPTIONS" {
+			return nil, errors.New("request error: asterisk target is only allowed for OPTIONS")
+		}
+		return &url.URL{Path: "*"}, nil
+	}
+
+	u, err := url.ParseRequestURI(target)
+	if err != nil {
+		return nil, errors.New("request error: invalid request target")
+	}
+	if u.IsAbs() {
+		if u.Host == "" || u.User != nil {
+			return nil, errors.New("request error: invalid absolute request target")
+		}
+		if u.Path == "" {
+			u.Path = "/"
+		}
+	} else if !strings.HasPrefix(target, "/") {
+		return nil, errors.New("request error: invalid request target")
+	}
+
+	if _, err := url.ParseQuery(u.RawQuery); err != nil {
+		return nil, errors.New("request error: invalid query in request target")
+	}
+
+	return u, nil
+}
+
+// resolveHost checks the Host header and sets r.Host. HTTP/1.1 requires
+// exactly one Host field. When the target carries its own authority, it
+// takes precedence over the Host header (RFC 9112 3.2.2).
+func (r *Request) resolveHost() error {
+	hosts := r.Headers.Values("host")
+	if len(hosts) == 0 && r.RequestLine.HttpVersion == HTTP11 {
+		return errors.New("request error: missing host header")
+	}
+	if len(hosts) > 1 {
+		return errors.New("request error: multiple host headers")
+	}
+	host := r.Headers.Get("host")
+	if host != "" && !isValidHost(host) {
+		return errors.New("request error: invalid host header")
+	}
+
+	if r.URL.Host != "" {
+		r.Host = r.URL.Host
+	} else {
+		r.Host = host
+	}
+	return nil
+}
+
+// isValidHost reports whether host is a uri-host with an optional numeric
+// port.
+func isValidHost(host string) bool {
+	u, err := url.Parse("//" + host)
+	return err == nil && u.Host == host && u.User == nil && u.Path == ""
+}
+
+// Path returns the decoded path of the request target.
+func (r *Request) Path() string {
+	return r.URL.Path
+}
+
+// RawPath returns the path of the request target as sent, with its
+// percent-encoding intact.
+func (r *Request) RawPath() string {
+	return r.URL.EscapedPath()
+}
+
+// Query returns the query parameters of the request target. Each key maps
+// to every value it was given with, in order.
+func (r *Request) Query() url.Values {
+	query, _ := url.ParseQuery(r.URL.RawQuery)
+	return query
+}
@@ -0,0 +1,122 @@
+package response
+
+import (
+	"fmt"
+	"go-http-server/internal/headers"
+	"strconv"
+)
+
+// BufferSize is how much of the body Write buffers before it gives up on
+// sending a Content-Length and switches to chunked encoding.
+const BufferSize = 4096
+
+type framing int
+
+const (
+	framingLength framing = iota
+	framingChunked
+	framingClose
+)
+
+// Header returns the headers sent with a response written through Write.
+// Changes after the first Flush, or once the body outgrows BufferSize,
+// have no effect.
+func (w *Writer) Header() *headers.Headers {
+	if w.header == nil {
+		w.header = headers.NewHeaders()
+	}
+	return w.header
+}
+
+// SetStatus sets the status sent with a response written through Write.
+// It defaults to 200.
+func (w *Writer) SetStatus(statusCode StatusCode) {
+	w.pendingStatus = statusCode
+}
+
+// Write buffers p as part of the response body. Bodies that fit in
+// BufferSize are sent with a Content-Length when the handler returns;
+// larger ones, or ones that are flushed early, switch to chunked encoding.
+// If Header has a Content-Length, the body is streamed as is instead.
+func (w *Writer) Write(p []byte) (int, error) {
+	switch w.state {
+	case writingStatusLine, writingHeaders:
+		if len(w.buf)+len(p) <= BufferSize {
+			w.buf = append(w.buf, p...)
+			return len(p), nil
+		}
+		if err := w.commit(framingChunked); err != nil {
+			return 0, err
+		}
+	case writingBody:
+	default:
+		return 0, fmt.Errorf("%w: body already complete", ErrWriteOrder)
+	}
+
+	if w.chunked {
+		return w.WriteChunkedBody(p)
+	}
+	return w.WriteBody(p)
+}
+
+// Flush sends the status line, headers and buffered body right away. The
+// rest of the body is then chunked unless Header has a Content-Length.
+func (w *Writer) Flush() error {
+	if w.state < writingBody {
+		return w.commit(framingChunked)
+	}
+	return nil
+}
+
+// commit writes the status line and headers of a response built with
+// SetStatus and Header, followed by the buffered body. f selects the body
+// framing when the handler did not set a Content-Length.
+func (w *Writer) commit(f framing) error {
+	if err := validateFields(w.Header()); err != nil {
+		if w.state == writingHeaders {
+			w.fail()
+		}
+		return err
+	}
+	if w.state == writingStatusLine {
+		status := w.pendingStatus
+		if status == 0 {
+			status = StatusOK
+		}
+		if err := w.WriteStatusLine(status); err != nil {
+			return err
+		}
+	}
+
+	h := w.Header()
+	if h.Get("content-type") == "" {
+		h.Set("content-type", "text/html")
+	}
+	switch {
+	case !bodyAllowed(w.status):
+		h.Delete("content-length")
+		h.Delete("transfer-encoding")
+		w.buf = nil
+	case h.Get("content-length") != "":
+	case f == framingLength:
+		h.Set("content-length", strconv.Itoa(len(w.buf)))
+	case f == framingChunked && w.httpVersion != "1.0":
+		h.Replace("transfer-encoding", "chunked")
+	}
+
+	if err := w.WriteHeaders(h); err != nil {
+		return err
+	}
+
+	buf := w.buf
+	w.buf = nil
+	if len(buf) == 0 {
+		return nil
+	}
+	if w.chunked {
+		_, err := w.WriteChunkedBody(buf)
+		return err
+	}
+	_, err := w.WriteBody(buf)
+	return err
+}
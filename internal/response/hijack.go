@@ -0,0 +1,55 @@
+package response
+
+import (
+	"errors"
+	"net"
+)
+
+// ErrHijacked is returned by writes to a Writer whose connection has been
+// hijacked.
+var ErrHijacked = errors.New("response error: connection hijacked")
+
+// Hijacker hands over the connection behind a Writer, along with the bytes
+// read from it but not parsed yet. The server sets one on every Writer it
+// creates.
+type Hijacker func() (net.Conn, []byte, error)
+
+type hijackedWriter struct{}
+
+func (hijackedWriter) Write([]byte) (int, error) {
+	return 0, ErrHijacked
+}
+
+// SetHijacker sets the function Hijack calls to take over the connection.
+func (w *Writer) SetHijacker(hijacker Hijacker) {
+	w.hijacker = hijacker
+}
+
+// Hijack takes over the connection. The server stops reading, writing,
+// timing out and closing it, and does not wait for it on Shutdown; the
+// caller must close it. Bytes already written stay sent, but a response
+// buffered through Write and not flushed is dropped. Any later write to w
+// fails with ErrHijacked.
+func (w *Writer) Hijack() (net.Conn, []byte, error) {
+	if w.writer == (hijackedWriter{}) {
+		return nil, nil, ErrHijacked
+	}
+	if w.hijacker == nil {
+		return nil, nil, errors.New("response error: connection cannot be hijacked")
+	}
+
+	conn, buffered, err := w.hijacker()
+	if err != nil {
+		return nil, nil, err
+	}
+	w.writer = hijackedWriter{}
+	w.state = writingDone
+	w.header = nil
+	w.buf = nil
+	return conn, buffered, nil
+}
+
+// Hijacked reports whether Hijack has taken over the connection.
+func (w *Writer) Hijacked() bool {
+	return w.writer == (hijackedWriter{})
+}
@@ -1,61 +1,294 @@
 package response
 
 import (
+	"errors"
 	"fmt"
 	"go-http-server/internal/headers"
 	"io"
 	"net/textproto"
+	"slices"
 	"strconv"
+	"strings"
 )
 
-type StatusCode int
+// ErrWriteOrder is returned, wrapped, by Writer methods called out of the
+// status line, headers, body, trailers order.
+var ErrWriteOrder = errors.New("response error: write out of order")
+
+// ErrResponseFailed is returned by Finish when the headers were rejected
+// after the status line had already been sent. The response cannot be
+// completed and the connection has to be closed.
+var ErrResponseFailed = errors.New("response error: headers rejected after status line")
+
+type writerState int
 
 const (
-	StatusOK                  StatusCode = 200
-	StatusBadRequest          StatusCode = 400
-	StatusInternalServerError StatusCode = 500
+	writingStatusLine writerState = iota
+	writingHeaders
+	writingBody
+	writingDone
+	// writingFailed means the status line went out but the headers were
+	// rejected, leaving a response that cannot be completed.
+	writingFailed
 )
 
 type Writer struct {
-	writer io.Writer
+	writer       io.Writer
+	httpVersion  string
+	state        writerState
+	keepAlive    bool
+	chunked      bool
+	status       StatusCode
+	bytesWritten int
+	// contentLength is the declared Content-Length, or -1 if there is none.
+	contentLength int
+	// trailers holds the lowercased field names announced in the Trailer
+	// header.
+	trailers []string
+	// dechunk is set when a chunked response goes to an HTTP/1.0 client,
+	// which gets the chunk data unframed and delimited by connection close.
+	dechunk bool
+
+	// sortHeaders writes fields sorted by name instead of in insertion
+	// order.
+	sortHeaders bool
+
+	hijacker Hijacker
+
+	// Buffered mode, see Write.
+	header        *headers.Headers
+	pendingStatus StatusCode
+	buf           []byte
 }
 
 func NewWriter(writer io.Writer) *Writer {
-	return &Writer{writer: writer}
+	return &Writer{
+		writer:        writer,
+		httpVersion:   "1.1",
+		state:         writingStatusLine,
+		keepAlive:     true,
+		contentLength: -1,
+	}
+}
+
+// KeepAlive reports whether the connection can be reused once the
+// response is complete.
+func (w *Writer) KeepAlive() bool {
+	return w.keepAlive
+}
+
+// SetKeepAlive must be called before WriteHeaders. When keepAlive is false
+// a "Connection: close" header is added to the response.
+func (w *Writer) SetKeepAlive(keepAlive bool) {
+	w.keepAlive = keepAlive
+}
+
+// SetHttpVersion sets the version of the status line, "1.0" or "1.1". It
+// must match the request. HTTP/1.0 responses never use chunked encoding
+// and only stay alive if the client asked for it.
+func (w *Writer) SetHttpVersion(version string) {
+	w.httpVersion = version
+}
+
+// SetSortHeaders makes WriteHeaders sort fields by name instead of keeping
+// the order they were added in. Either way Date, Content-Type and
+// Content-Length come first, in that order.
+func (w *Writer) SetSortHeaders(sort bool) {
+	w.sortHeaders = sort
+}
+
+// Status returns the status code of the response, or 0 if the handler has
+// not written anything yet. A buffered response counts as written once
+// SetStatus was called or body bytes were buffered; setting headers alone
+// does not.
+func (w *Writer) Status() StatusCode {
+	if w.status != 0 {
+		return w.status
+	}
+	if w.pendingStatus != 0 {
+		return w.pendingStatus
+	}
+	if len(w.buf) > 0 {
+		return StatusOK
+	}
+	return 0
+}
+
+// Started reports whether the status line of the final response has been
+// written, so that nothing else can be sent in its place.
+func (w *Writer) Started() bool {
+	return w.state != writingStatusLine
+}
+
+// BytesWritten returns the number of body bytes written or buffered so far,
+// excluding chunked framing.
+func (w *Writer) BytesWritten() int {
+	return w.bytesWritten + len(w.buf)
 }
 
 func (w *Writer) WriteStatusLine(statusCode StatusCode) error {
-	var response string
-	switch statusCode {
-	case StatusOK:
-		response = "HTTP/1.1 200 OK\r\n"
-	case StatusBadRequest:
-		response = "HTTP/1.1 400 Bad Request\r\n"
-	case StatusInternalServerError:
-		response = "HTTP/1.1 500 Internal Server Error\r\n"
-	default:
-		response = ""
-	}
-
-	_, err := w.writer.Write([]byte(response))
+	return w.WriteStatusLineWithReason(statusCode, StatusText(statusCode))
+}
+
+// WriteStatusLineWithReason writes a status line with a custom reason
+// phrase. The phrase may be empty.
+func (w *Writer) WriteStatusLineWithReason(statusCode StatusCode, reason string) error {
+	if w.state != writingStatusLine {
+		return fmt.Errorf("%w: status line already written", ErrWriteOrder)
+	}
+	if statusCode < 100 || statusCode > 999 {
+		return fmt.Errorf("response error: invalid status code %d", statusCode)
+	}
+	if !isValidReasonPhrase(reason) {
+		return errors.New("response error: invalid character in reason phrase")
+	}
+
+	w.status = statusCode
+	w.state = writingHeaders
+	_, err := fmt.Fprintf(w.writer, "HTTP/%s %d %s\r\n", w.httpVersion, statusCode, reason)
+	return err
+}
+
+// WriteInformational sends a 1xx interim response with the given headers,
+// which may be nil. Any number of them can be sent before the final status
+// line. HTTP/1.0 clients do not understand interim responses, so nothing
+// is sent to them.
+func (w *Writer) WriteInformational(statusCode StatusCode, h *headers.Headers) error {
+	if w.state != writingStatusLine {
+		return fmt.Errorf("%w: final status already written", ErrWriteOrder)
+	}
+	if statusCode < 100 || statusCode > 199 {
+		return fmt.Errorf("response error: %d is not an informational status", statusCode)
+	}
+	if statusCode == StatusSwitchingProtocols {
+		return errors.New("response error: 101 Switching Protocols must be the final status")
+	}
+	if h == nil {
+		h = headers.NewHeaders()
+	}
+	if err := validateFields(h); err != nil {
+		return err
+	}
+	if w.httpVersion == "1.0" {
+		return nil
+	}
+
+	b := fmt.Appendf(nil, "HTTP/%s %d %s\r\n", w.httpVersion, statusCode, StatusText(statusCode))
+	for _, f := range w.orderFields(h) {
+		b = appendField(b, f.Name, f.Value)
+	}
+	b = append(b, '\r', '\n')
+	_, err := w.writer.Write(b)
 	return err
 }
 
-func (w *Writer) WriteHeaders(headers headers.Headers) error {
+// WriteContinue sends a 100 Continue interim response, telling a client
+// that sent "Expect: 100-continue" to go ahead with the body.
+func (w *Writer) WriteContinue() error {
+	return w.WriteInformational(StatusContinue, nil)
+}
+
+// WriteEarlyHints sends a 103 Early Hints interim response, typically with
+// Link headers the client can preload while the final response is built.
+func (w *Writer) WriteEarlyHints(h *headers.Headers) error {
+	return w.WriteInformational(StatusEarlyHints, h)
+}
+
+func (w *Writer) WriteHeaders(headers *headers.Headers) error {
+	switch w.state {
+	case writingStatusLine:
+		return fmt.Errorf("%w: headers written before status line", ErrWriteOrder)
+	case writingBody, writingDone, writingFailed:
+		return fmt.Errorf("%w: headers already written", ErrWriteOrder)
+	}
+	if err := validateFields(headers); err != nil {
+		w.fail()
+		return err
+	}
+
+	w.chunked = headers.HasToken("transfer-encoding", "chunked")
+	w.dechunk = w.chunked && w.httpVersion == "1.0"
+
+	if n, err := strconv.Atoi(headers.Get("content-length")); err == nil {
+		w.contentLength = n
+	}
+	if !bodyAllowed(w.status) {
+		w.contentLength = 0
+	} else if !hasMessageLength(headers) || w.dechunk {
+		w.keepAlive = false
+	}
+	sentClose := headers.HasToken("connection", "close")
+	if sentClose {
+		w.keepAlive = false
+	}
+
 	b := []byte{}
-	for k, v := range headers {
-		b = fmt.Appendf(b, "%s: %s\r\n", textproto.CanonicalMIMEHeaderKey(k), v)
+	for _, f := range w.orderFields(headers) {
+		if w.dechunk && (strings.EqualFold(f.Name, "transfer-encoding") || strings.EqualFold(f.Name, "trailer")) {
+			continue
+		}
+		// A handler's Connection header cannot promise a connection
+		// that will be closed anyway.
+		if !w.keepAlive && !sentClose && strings.EqualFold(f.Name, "connection") {
+			continue
+		}
+		b = appendField(b, f.Name, f.Value)
+	}
+	switch {
+	case !w.keepAlive && !sentClose:
+		b = append(b, "Connection: close\r\n"...)
+	case w.keepAlive && w.httpVersion == "1.0" && headers.Get("connection") == "":
+		b = append(b, "Connection: keep-alive\r\n"...)
 	}
+	w.trailers = trailerNames(headers)
+	w.state = writingBody
 	b = append(b, '\r', '\n')
 	_, err := w.writer.Write(b)
 	return err
 }
 
+// WriteBody writes p as is. If the handler has not written the status line
+// or headers yet, a 200 status and the default headers are sent first and
+// the body is delimited by closing the connection.
 func (w *Writer) WriteBody(p []byte) (int, error) {
-	return w.writer.Write(p)
+	if w.state < writingBody {
+		if err := w.commit(framingClose); err != nil {
+			return 0, err
+		}
+	}
+	if w.state != writingBody {
+		return 0, fmt.Errorf("%w: body already complete", ErrWriteOrder)
+	}
+	if w.chunked {
+		return 0, fmt.Errorf("%w: use WriteChunkedBody for a chunked response", ErrWriteOrder)
+	}
+
+	n, err := w.writer.Write(p)
+	w.bytesWritten += n
+	return n, err
 }
 
+// WriteChunkedBody writes p as one chunk. If the handler has not written the
+// status line or headers yet, a 200 status and the default headers with
+// "Transfer-Encoding: chunked" are sent first.
 func (w *Writer) WriteChunkedBody(p []byte) (int, error) {
+	if w.state < writingBody {
+		if err := w.commit(framingChunked); err != nil {
+			return 0, err
+		}
+	}
+	if w.state != writingBody {
+		return 0, fmt.Errorf("%w: body already complete", ErrWriteOrder)
+	}
+	if !w.chunked {
+		return 0, fmt.Errorf("%w: response is not chunked", ErrWriteOrder)
+	}
+	if w.dechunk {
+		n, err := w.writer.Write(p)
+		w.bytesWritten += n
+		return n, err
+	}
+
 	chunkHeader := strconv.FormatInt(int64(len(p)), 16)
 	b := make([]byte, 0, len(chunkHeader)+len(p)+4)
 	b = append(b, chunkHeader...)
@@ -63,18 +296,213 @@ func (w *Writer) WriteChunkedBody(p []byte) (int, error) {
 	b = append(b, p...)
 	b = append(b, '\r', '\n')
 
-	return w.writer.Write(b)
+	n, err := w.writer.Write(b)
+	if err != nil {
+		return n, err
+	}
+	w.bytesWritten += len(p)
+	return len(p), nil
 }
 
+// WriteChunkedBodyDone ends a chunked body without trailers. Use
+// WriteTrailers instead to send trailer fields.
 func (w *Writer) WriteChunkedBodyDone() (int, error) {
-	return w.writer.Write([]byte("0\r\n"))
+	if w.state != writingBody || !w.chunked {
+		return 0, fmt.Errorf("%w: no chunked body in progress", ErrWriteOrder)
+	}
+	w.state = writingDone
+	if w.dechunk {
+		return 0, nil
+	}
+	return w.writer.Write([]byte("0\r\n\r\n"))
+}
+
+// WriteTrailers ends a chunked body with the given trailer fields. Every
+// field must have been announced in the Trailer header, and fields that
+// affect framing, routing or authentication are rejected.
+func (w *Writer) WriteTrailers(trailers *headers.Headers) error {
+	announced := w.trailers
+	if w.state < writingBody {
+		announced = trailerNames(w.Header())
+	}
+	if err := validateFields(trailers); err != nil {
+		return err
+	}
+	for name := range trailers.All() {
+		name = strings.ToLower(name)
+		if forbiddenTrailers[name] {
+			return fmt.Errorf("response error: %s is not allowed in trailers", textproto.CanonicalMIMEHeaderKey(name))
+		}
+		if !slices.Contains(announced, name) {
+			return fmt.Errorf("response error: trailer %s was not announced", textproto.CanonicalMIMEHeaderKey(name))
+		}
+	}
+
+	if w.state < writingBody {
+		if err := w.commit(framingChunked); err != nil {
+			return err
+		}
+	}
+	if w.state != writingBody || !w.chunked {
+		return fmt.Errorf("%w: no chunked body in progress", ErrWriteOrder)
+	}
+
+	w.state = writingDone
+	if w.dechunk {
+		return nil
+	}
+
+	b := []byte("0\r\n")
+	for k, v := range trailers.All() {
+		b = appendField(b, k, v)
+	}
+	b = append(b, '\r', '\n')
+	_, err := w.writer.Write(b)
+	return err
 }
 
-func GetDefaultHeaders(contentLength int) headers.Headers {
+// Finish completes whatever the handler left unfinished: a buffered
+// response is sent with a Content-Length, missing headers are written as
+// defaults, and an open chunked body is terminated. A body shorter than its
+// Content-Length disables keep-alive, since the client can only recover by
+// seeing the connection close. It fails if the handler wrote nothing.
+func (w *Writer) Finish() error {
+	switch w.state {
+	case writingFailed:
+		return ErrResponseFailed
+	case writingStatusLine, writingHeaders:
+		if w.Status() == 0 {
+			return fmt.Errorf("%w: nothing written", ErrWriteOrder)
+		}
+		return w.commit(framingLength)
+	case writingBody:
+		if !w.chunked {
+			if w.contentLength >= 0 && w.bytesWritten != w.contentLength {
+				w.keepAlive = false
+			}
+			w.state = writingDone
+			return nil
+		}
+		_, err := w.WriteChunkedBodyDone()
+		return err
+	}
+	return nil
+}
+
+// fail marks a response whose headers were rejected after the status line
+// was sent. Nothing more is written and the connection is not reused.
+func (w *Writer) fail() {
+	w.state = writingFailed
+	w.keepAlive = false
+}
+
+func GetDefaultHeaders(contentLength int) *headers.Headers {
 	h := headers.NewHeaders()
 	h.Set("content-length", fmt.Sprintf("%d", contentLength))
-	h.Set("connection", "close")
 	h.Set("Content-type", "text/html")
 
 	return h
 }
+
+// forbiddenTrailers lists fields that must not be sent as trailers because
+// recipients need them before the body (RFC 9110 6.5.1).
+var forbiddenTrailers = map[string]bool{
+	"authorization":       true,
+	"cache-control":       true,
+	"connection":          true,
+	"content-encoding":    true,
+	"content-length":      true,
+	"content-range":       true,
+	"content-type":        true,
+	"expect":              true,
+	"host":                true,
+	"keep-alive":          true,
+	"max-forwards":        true,
+	"pragma":              true,
+	"proxy-authenticate":  true,
+	"proxy-authorization": true,
+	"proxy-connection":    true,
+	"range":               true,
+	"te":                  true,
+	"trailer":             true,
+	"transfer-encoding":   true,
+	"www-authenticate":    true,
+}
+
+// leadingFields are written before every other field, in this order.
+var leadingFields = []string{"date", "content-type", "content-length"}
+
+// orderFields returns the field lines of h in the order they are written.
+func (w *Writer) orderFields(h *headers.Headers) []headers.Field {
+	fields := []headers.Field{}
+	for _, name := range leadingFields {
+		for _, v := range h.Values(name) {
+			fields = append(fields, headers.Field{Name: name, Value: v})
+		}
+	}
+	rest := []headers.Field{}
+	for k, v := range h.All() {
+		if !slices.Contains(leadingFields, strings.ToLower(k)) {
+			rest = append(rest, headers.Field{Name: k, Value: v})
+		}
+	}
+	if w.sortHeaders {
+		slices.SortStableFunc(rest, func(a, b headers.Field) int {
+			return strings.Compare(strings.ToLower(a.Name), strings.ToLower(b.Name))
+		})
+	}
+	return append(fields, rest...)
+}
+
+// trailerNames returns the lowercased field names announced in the Trailer
+// header of h.
+func trailerNames(h *headers.Headers) []string {
+	names := []string{}
+	for _, name := range strings.Split(h.Get("trailer"), ",") {
+		if name = strings.TrimSpace(name); name != "" {
+			names = append(names, strings.ToLower(name))
+		}
+	}
+	return names
+}
+
+// validateFields rejects field lines that would corrupt the message, such
+// as a value carrying CR or LF from user input.
+func validateFields(h *headers.Headers) error {
+	for name, value := range h.All() {
+		if !headers.IsValidFieldName(name) {
+			return fmt.Errorf("response error: invalid field name %q", name)
+		}
+		if !headers.IsValidFieldValue(value) {
+			return fmt.Errorf("response error: invalid value for field %s", textproto.CanonicalMIMEHeaderKey(name))
+		}
+	}
+	return nil
+}
+
+func appendField(b []byte, name string, value string) []byte {
+	return fmt.Appendf(b, "%s: %s\r\n", textproto.CanonicalMIMEHeaderKey(name), value)
+}
+
+// bodyAllowed reports whether a response with status may have content.
+func bodyAllowed(status StatusCode) bool {
+	return status >= 200 && status != StatusNoContent && status != StatusNotModified
+}
+
+// hasMessageLength reports whether the client can find the end of the body
+// without the connection being closed.
+func hasMessageLength(h *headers.Headers) bool {
+	return h.Get("content-length") != "" || h.HasToken("transfer-encoding", "chunked")
+}
+
+// isValidReasonPhrase checks the reason-phrase grammar from RFC 9112 4:
+// HTAB, SP, VCHAR and obs-text.
+func isValidReasonPhrase(reason string) bool {
+	for i := 0; i < len(reason); i++ {
+		b := reason[i]
+		if b != '\t' && (b < ' ' || b == 0x7f) {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,532 @@
+package response
+
+import (
+	"bytes"
+	"fmt"
+	"go-http-server/internal/headers"
+	"net"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWriteStatusLine(t *testing.T) {
+	// Test: Known status codes
+	for code, want := range map[StatusCode]string{
+		StatusOK:                         "HTTP/1.1 200 OK\r\n",
+		StatusFound:                      "HTTP/1.1 302 Found\r\n",
+		StatusNotFound:                   "HTTP/1.1 404 Not Found\r\n",
+		StatusTooManyRequests:            "HTTP/1.1 429 Too Many Requests\r\n",
+		StatusUnavailableForLegalReasons: "HTTP/1.1 451 Unavailable For Legal Reasons\r\n",
+		StatusServiceUnavailable:         "HTTP/1.1 503 Service Unavailable\r\n",
+	} {
+		buf := &bytes.Buffer{}
+		w := NewWriter(buf)
+		require.NoError(t, w.WriteStatusLine(code))
+		assert.Equal(t, want, buf.String())
+		assert.Equal(t, code, w.Status())
+	}
+
+	// Test: Unknown status code keeps an empty reason phrase
+	buf := &bytes.Buffer{}
+	require.NoError(t, NewWriter(buf).WriteStatusLine(599))
+	assert.Equal(t, "HTTP/1.1 599 \r\n", buf.String())
+
+	// Test: Custom reason phrase
+	buf = &bytes.Buffer{}
+	require.NoError(t, NewWriter(buf).WriteStatusLineWithReason(StatusOK, "Totally Fine"))
+	assert.Equal(t, "HTTP/1.1 200 Totally Fine\r\n", buf.String())
+
+	// Test: Reason phrase cannot break the status line
+	buf = &bytes.Buffer{}
+	require.Error(t, NewWriter(buf).WriteStatusLineWithReason(StatusOK, "OK\r\nX-Injected: 1"))
+	assert.Empty(t, buf.String())
+
+	// Test: Status code must have three digits
+	buf = &bytes.Buffer{}
+	require.Error(t, NewWriter(buf).WriteStatusLine(1000))
+	require.Error(t, NewWriter(buf).WriteStatusLine(99))
+	assert.Empty(t, buf.String())
+}
+
+func TestWriterOrder(t *testing.T) {
+	// Test: Body first sends 200 with default headers and closes the connection
+	buf := &bytes.Buffer{}
+	w := NewWriter(buf)
+	_, err := w.WriteBody([]byte("hello"))
+	require.NoError(t, err)
+	require.NoError(t, w.Finish())
+	assert.Equal(t, StatusOK, w.Status())
+	assert.True(t, strings.HasPrefix(buf.String(), "HTTP/1.1 200 OK\r\n"))
+	assert.Contains(t, buf.String(), "Connection: close\r\n")
+	assert.True(t, strings.HasSuffix(buf.String(), "\r\n\r\nhello"))
+	assert.False(t, w.KeepAlive())
+
+	// Test: Headers before status line
+	w = NewWriter(&bytes.Buffer{})
+	assert.ErrorIs(t, w.WriteHeaders(GetDefaultHeaders(0)), ErrWriteOrder)
+
+	// Test: Status line and headers written twice
+	w = NewWriter(&bytes.Buffer{})
+	require.NoError(t, w.WriteStatusLine(StatusOK))
+	assert.ErrorIs(t, w.WriteStatusLine(StatusOK), ErrWriteOrder)
+	require.NoError(t, w.WriteHeaders(GetDefaultHeaders(0)))
+	assert.ErrorIs(t, w.WriteHeaders(GetDefaultHeaders(0)), ErrWriteOrder)
+	assert.True(t, w.KeepAlive())
+
+	// Test: Chunk written to a Content-Length response
+	w = NewWriter(&bytes.Buffer{})
+	require.NoError(t, w.WriteStatusLine(StatusOK))
+	require.NoError(t, w.WriteHeaders(GetDefaultHeaders(2)))
+	_, err = w.WriteChunkedBody([]byte("hi"))
+	assert.ErrorIs(t, err, ErrWriteOrder)
+
+	// Test: Finish writes missing headers
+	buf = &bytes.Buffer{}
+	w = NewWriter(buf)
+	require.NoError(t, w.WriteStatusLine(StatusAccepted))
+	require.NoError(t, w.Finish())
+	assert.Equal(t, "HTTP/1.1 202 Accepted\r\nContent-Type: text/html\r\nContent-Length: 0\r\n\r\n", buf.String())
+	assert.True(t, w.KeepAlive())
+
+	// Test: Finish terminates an open chunked body
+	buf = &bytes.Buffer{}
+	w = NewWriter(buf)
+	_, err = w.WriteChunkedBody([]byte("hi"))
+	require.NoError(t, err)
+	require.NoError(t, w.Finish())
+	assert.True(t, strings.HasSuffix(buf.String(), "\r\n\r\n2\r\nhi\r\n0\r\n\r\n"))
+	assert.True(t, w.KeepAlive())
+
+	// Test: Short body disables keep-alive
+	w = NewWriter(&bytes.Buffer{})
+	require.NoError(t, w.WriteStatusLine(StatusOK))
+	require.NoError(t, w.WriteHeaders(GetDefaultHeaders(10)))
+	_, err = w.WriteBody([]byte("short"))
+	require.NoError(t, err)
+	require.NoError(t, w.Finish())
+	assert.False(t, w.KeepAlive())
+
+	// Test: Nothing can be written after the response is complete
+	_, err = w.WriteBody([]byte("more"))
+	assert.ErrorIs(t, err, ErrWriteOrder)
+}
+
+func TestBufferedWrite(t *testing.T) {
+	// Test: Small body gets a Content-Length
+	buf := &bytes.Buffer{}
+	w := NewWriter(buf)
+	w.SetStatus(StatusCreated)
+	w.Header().Set("content-type", "text/plain")
+	fmt.Fprint(w, "hello ")
+	fmt.Fprint(w, "world")
+	assert.Equal(t, StatusCreated, w.Status())
+	assert.Equal(t, 11, w.BytesWritten())
+	assert.Empty(t, buf.String())
+	assert.False(t, w.Started())
+	require.NoError(t, w.Finish())
+	assert.True(t, w.Started())
+	assert.Equal(t, "HTTP/1.1 201 Created\r\nContent-Type: text/plain\r\nContent-Length: 11\r\n\r\nhello world", buf.String())
+	assert.True(t, w.KeepAlive())
+
+	// Test: Body over the buffer size switches to chunked
+	buf = &bytes.Buffer{}
+	w = NewWriter(buf)
+	_, err := w.Write(bytes.Repeat([]byte("a"), BufferSize))
+	require.NoError(t, err)
+	_, err = w.Write([]byte("b"))
+	require.NoError(t, err)
+	require.NoError(t, w.Finish())
+	out := buf.String()
+	assert.Contains(t, out, "Transfer-Encoding: chunked\r\n")
+	assert.NotContains(t, out, "Content-Length")
+	assert.True(t, strings.HasSuffix(out, "\r\n1000\r\n"+strings.Repeat("a", BufferSize)+"\r\n1\r\nb\r\n0\r\n\r\n"))
+	assert.Equal(t, BufferSize+1, w.BytesWritten())
+	assert.True(t, w.KeepAlive())
+
+	// Test: Flush switches to chunked
+	buf = &bytes.Buffer{}
+	w = NewWriter(buf)
+	fmt.Fprint(w, "early")
+	require.NoError(t, w.Flush())
+	assert.True(t, strings.HasSuffix(buf.String(), "\r\n\r\n5\r\nearly\r\n"))
+	fmt.Fprint(w, "late")
+	require.NoError(t, w.Finish())
+	assert.True(t, strings.HasSuffix(buf.String(), "\r\n5\r\nearly\r\n4\r\nlate\r\n0\r\n\r\n"))
+
+	// Test: Handler-provided Content-Length is streamed as is
+	buf = &bytes.Buffer{}
+	w = NewWriter(buf)
+	w.Header().Set("content-length", strconv.Itoa(BufferSize+1))
+	_, err = w.Write(bytes.Repeat([]byte("a"), BufferSize+1))
+	require.NoError(t, err)
+	require.NoError(t, w.Finish())
+	assert.NotContains(t, buf.String(), "Transfer-Encoding")
+	assert.True(t, strings.HasSuffix(buf.String(), "\r\n\r\n"+strings.Repeat("a", BufferSize+1)))
+	assert.True(t, w.KeepAlive())
+
+	// Test: Each Set-Cookie gets its own line
+	buf = &bytes.Buffer{}
+	w = NewWriter(buf)
+	w.SetStatus(StatusOK)
+	w.Header().Add("set-cookie", "a=1; Expires=Wed, 21 Oct 2026 07:28:00 GMT")
+	w.Header().Add("set-cookie", "b=2")
+	require.NoError(t, w.Finish())
+	assert.Contains(t, buf.String(), "\r\nSet-Cookie: a=1; Expires=Wed, 21 Oct 2026 07:28:00 GMT\r\nSet-Cookie: b=2\r\n")
+
+	// Test: No content for 204
+	buf = &bytes.Buffer{}
+	w = NewWriter(buf)
+	w.SetStatus(StatusNoContent)
+	require.NoError(t, w.Finish())
+	assert.NotContains(t, buf.String(), "Content-Length")
+	assert.True(t, w.KeepAlive())
+
+	// Test: Nothing written
+	w = NewWriter(&bytes.Buffer{})
+	assert.Equal(t, StatusCode(0), w.Status())
+	assert.ErrorIs(t, w.Finish(), ErrWriteOrder)
+
+	// Test: Headers alone do not count as written
+	w = NewWriter(&bytes.Buffer{})
+	w.Header().Set("x-request-id", "1")
+	assert.Equal(t, StatusCode(0), w.Status())
+	assert.ErrorIs(t, w.Finish(), ErrWriteOrder)
+}
+
+func TestWriteTrailers(t *testing.T) {
+	chunkedHeaders := func(trailer string) *headers.Headers {
+		h := GetDefaultHeaders(0)
+		h.Delete("content-length")
+		h.Set("transfer-encoding", "chunked")
+		if trailer != "" {
+			h.Set("trailer", trailer)
+		}
+		return h
+	}
+
+	// Test: Announced trailers
+	buf := &bytes.Buffer{}
+	w := NewWriter(buf)
+	require.NoError(t, w.WriteStatusLine(StatusOK))
+	require.NoError(t, w.WriteHeaders(chunkedHeaders("X-Checksum")))
+	_, err := w.WriteChunkedBody([]byte("hello"))
+	require.NoError(t, err)
+	trailers := headers.NewHeaders()
+	trailers.Set("X-Checksum", "abc")
+	require.NoError(t, w.WriteTrailers(trailers))
+	assert.True(t, strings.HasSuffix(buf.String(), "\r\n5\r\nhello\r\n0\r\nX-Checksum: abc\r\n\r\n"))
+	require.NoError(t, w.Finish())
+	assert.True(t, w.KeepAlive())
+
+	// Test: No trailers still ends the message
+	buf = &bytes.Buffer{}
+	w = NewWriter(buf)
+	require.NoError(t, w.WriteStatusLine(StatusOK))
+	require.NoError(t, w.WriteHeaders(chunkedHeaders("")))
+	require.NoError(t, w.WriteTrailers(headers.NewHeaders()))
+	assert.True(t, strings.HasSuffix(buf.String(), "\r\n\r\n0\r\n\r\n"))
+
+	// Test: WriteChunkedBodyDone ends the message without trailers
+	buf = &bytes.Buffer{}
+	w = NewWriter(buf)
+	_, err = w.WriteChunkedBody([]byte("hi"))
+	require.NoError(t, err)
+	_, err = w.WriteChunkedBodyDone()
+	require.NoError(t, err)
+	assert.True(t, strings.HasSuffix(buf.String(), "\r\n2\r\nhi\r\n0\r\n\r\n"))
+	assert.ErrorIs(t, w.WriteTrailers(headers.NewHeaders()), ErrWriteOrder)
+
+	// Test: Trailer not announced
+	w = NewWriter(&bytes.Buffer{})
+	require.NoError(t, w.WriteStatusLine(StatusOK))
+	require.NoError(t, w.WriteHeaders(chunkedHeaders("X-Checksum")))
+	trailers = headers.NewHeaders()
+	trailers.Set("X-Other", "1")
+	require.Error(t, w.WriteTrailers(trailers))
+
+	// Test: Forbidden trailer even when announced
+	w = NewWriter(&bytes.Buffer{})
+	require.NoError(t, w.WriteStatusLine(StatusOK))
+	require.NoError(t, w.WriteHeaders(chunkedHeaders("Content-Length")))
+	trailers = headers.NewHeaders()
+	trailers.Set("Content-Length", "5")
+	require.Error(t, w.WriteTrailers(trailers))
+
+	// Test: Trailers need a chunked body
+	w = NewWriter(&bytes.Buffer{})
+	require.NoError(t, w.WriteStatusLine(StatusOK))
+	require.NoError(t, w.WriteHeaders(GetDefaultHeaders(0)))
+	assert.ErrorIs(t, w.WriteTrailers(headers.NewHeaders()), ErrWriteOrder)
+
+	// Test: Buffered body switches to chunked for trailers
+	buf = &bytes.Buffer{}
+	w = NewWriter(buf)
+	w.Header().Set("trailer", "X-Checksum")
+	fmt.Fprint(w, "data")
+	trailers = headers.NewHeaders()
+	trailers.Set("X-Checksum", "abc")
+	require.NoError(t, w.WriteTrailers(trailers))
+	assert.Contains(t, buf.String(), "Transfer-Encoding: chunked\r\n")
+	assert.True(t, strings.HasSuffix(buf.String(), "\r\n4\r\ndata\r\n0\r\nX-Checksum: abc\r\n\r\n"))
+}
+
+func TestHttp10Response(t *testing.T) {
+	// Test: Status line version and keep-alive header
+	buf := &bytes.Buffer{}
+	w := NewWriter(buf)
+	w.SetHttpVersion("1.0")
+	fmt.Fprint(w, "hello")
+	require.NoError(t, w.Finish())
+	assert.True(t, strings.HasPrefix(buf.String(), "HTTP/1.0 200 OK\r\n"))
+	assert.Contains(t, buf.String(), "Connection: keep-alive\r\n")
+	assert.Contains(t, buf.String(), "Content-Length: 5\r\n")
+	assert.True(t, w.KeepAlive())
+
+	// Test: Large buffered body is close-delimited instead of chunked
+	buf = &bytes.Buffer{}
+	w = NewWriter(buf)
+	w.SetHttpVersion("1.0")
+	_, err := w.Write(bytes.Repeat([]byte("a"), BufferSize+1))
+	require.NoError(t, err)
+	require.NoError(t, w.Finish())
+	assert.NotContains(t, buf.String(), "Transfer-Encoding")
+	assert.Contains(t, buf.String(), "Connection: close\r\n")
+	assert.True(t, strings.HasSuffix(buf.String(), "\r\n\r\n"+strings.Repeat("a", BufferSize+1)))
+	assert.False(t, w.KeepAlive())
+
+	// Test: Explicit chunked response is sent unframed
+	buf = &bytes.Buffer{}
+	w = NewWriter(buf)
+	w.SetHttpVersion("1.0")
+	require.NoError(t, w.WriteStatusLine(StatusOK))
+	h := GetDefaultHeaders(0)
+	h.Delete("content-length")
+	h.Set("transfer-encoding", "chunked")
+	h.Set("trailer", "X-Checksum")
+	require.NoError(t, w.WriteHeaders(h))
+	_, err = w.WriteChunkedBody([]byte("hello "))
+	require.NoError(t, err)
+	_, err = w.WriteChunkedBody([]byte("world"))
+	require.NoError(t, err)
+	trailers := headers.NewHeaders()
+	trailers.Set("X-Checksum", "abc")
+	require.NoError(t, w.WriteTrailers(trailers))
+	assert.Equal(t, "HTTP/1.0 200 OK\r\nContent-Type: text/html\r\nConnection: close\r\n\r\nhello world", buf.String())
+	assert.False(t, w.KeepAlive())
+
+	// Test: Handler's keep-alive is replaced when the server closes
+	buf = &bytes.Buffer{}
+	w = NewWriter(buf)
+	w.SetHttpVersion("1.0")
+	w.SetKeepAlive(false)
+	require.NoError(t, w.WriteStatusLine(StatusOK))
+	h = GetDefaultHeaders(0)
+	h.Set("connection", "keep-alive")
+	require.NoError(t, w.WriteHeaders(h))
+	assert.Equal(t, "HTTP/1.0 200 OK\r\nContent-Type: text/html\r\nContent-Length: 0\r\nConnection: close\r\n\r\n", buf.String())
+}
+
+func TestHeaderOrder(t *testing.T) {
+	h := headers.NewHeaders()
+	h.Set("X-Zeta", "1")
+	h.Set("Content-Length", "0")
+	h.Set("Cache-Control", "no-store")
+	h.Set("Date", "Wed, 21 Oct 2026 07:28:00 GMT")
+	h.Set("X-Alpha", "2")
+	h.Set("Content-Type", "text/plain")
+
+	// Test: Insertion order after the leading fields
+	buf := &bytes.Buffer{}
+	w := NewWriter(buf)
+	require.NoError(t, w.WriteStatusLine(StatusOK))
+	require.NoError(t, w.WriteHeaders(h))
+	assert.Equal(t, "HTTP/1.1 200 OK\r\n"+
+		"Date: Wed, 21 Oct 2026 07:28:00 GMT\r\n"+
+		"Content-Type: text/plain\r\n"+
+		"Content-Length: 0\r\n"+
+		"X-Zeta: 1\r\n"+
+		"Cache-Control: no-store\r\n"+
+		"X-Alpha: 2\r\n"+
+		"\r\n", buf.String())
+
+	// Test: Sorted mode
+	buf = &bytes.Buffer{}
+	w = NewWriter(buf)
+	w.SetSortHeaders(true)
+	require.NoError(t, w.WriteStatusLine(StatusOK))
+	require.NoError(t, w.WriteHeaders(h))
+	assert.Equal(t, "HTTP/1.1 200 OK\r\n"+
+		"Date: Wed, 21 Oct 2026 07:28:00 GMT\r\n"+
+		"Content-Type: text/plain\r\n"+
+		"Content-Length: 0\r\n"+
+		"Cache-Control: no-store\r\n"+
+		"X-Alpha: 2\r\n"+
+		"X-Zeta: 1\r\n"+
+		"\r\n", buf.String())
+}
+
+func TestHeaderInjection(t *testing.T) {
+	// Test: WriteHeaders rejects CRLF in a value
+	buf := &bytes.Buffer{}
+	w := NewWriter(buf)
+	require.NoError(t, w.WriteStatusLine(StatusOK))
+	h := headers.NewHeaders()
+	h.Set("Location", "/next\r\nSet-Cookie: admin=1")
+	require.Error(t, w.WriteHeaders(h))
+	assert.Equal(t, "HTTP/1.1 200 OK\r\n", buf.String())
+
+	// Test: Finish fails after rejected headers and sends nothing more
+	assert.ErrorIs(t, w.Finish(), ErrResponseFailed)
+	assert.False(t, w.KeepAlive())
+	_, err := w.WriteBody([]byte("x"))
+	require.Error(t, err)
+	assert.Equal(t, "HTTP/1.1 200 OK\r\n", buf.String())
+
+	// Test: WriteHeaders rejects an invalid name
+	for _, name := range []string{"X-Bad\r\nName", ""} {
+		buf = &bytes.Buffer{}
+		w = NewWriter(buf)
+		require.NoError(t, w.WriteStatusLine(StatusOK))
+		h = headers.NewHeaders()
+		h.Set(name, "1")
+		require.Error(t, w.WriteHeaders(h))
+		assert.Equal(t, "HTTP/1.1 200 OK\r\n", buf.String())
+	}
+
+	// Test: Buffered headers rejected after a direct status line
+	buf = &bytes.Buffer{}
+	w = NewWriter(buf)
+	require.NoError(t, w.WriteStatusLine(StatusOK))
+	w.Header().Set("X-Echo", "a\nb")
+	require.Error(t, w.Finish())
+	assert.ErrorIs(t, w.Finish(), ErrResponseFailed)
+	assert.Equal(t, "HTTP/1.1 200 OK\r\n", buf.String())
+
+	// Test: Buffered response sends nothing
+	buf = &bytes.Buffer{}
+	w = NewWriter(buf)
+	w.Header().Set("X-Echo", "a\nb")
+	fmt.Fprint(w, "hello")
+	require.Error(t, w.Finish())
+	assert.Empty(t, buf.String())
+
+	// Test: WriteTrailers rejects NUL in a value
+	buf = &bytes.Buffer{}
+	w = NewWriter(buf)
+	w.Header().Set("Trailer", "X-Checksum")
+	trailers := headers.NewHeaders()
+	trailers.Set("X-Checksum", "a\x00b")
+	require.Error(t, w.WriteTrailers(trailers))
+}
+
+func TestWriteContinue(t *testing.T) {
+	// Test: Interim response before the final one
+	buf := &bytes.Buffer{}
+	w := NewWriter(buf)
+	require.NoError(t, w.WriteContinue())
+	w.SetStatus(StatusCreated)
+	fmt.Fprint(w, "ok")
+	require.NoError(t, w.Finish())
+	assert.Equal(t, "HTTP/1.1 100 Continue\r\n\r\nHTTP/1.1 201 Created\r\nContent-Type: text/html\r\nContent-Length: 2\r\n\r\nok", buf.String())
+
+	// Test: Not after the final status line
+	buf = &bytes.Buffer{}
+	w = NewWriter(buf)
+	require.NoError(t, w.WriteStatusLine(StatusContentTooLarge))
+	assert.ErrorIs(t, w.WriteContinue(), ErrWriteOrder)
+	assert.Equal(t, "HTTP/1.1 413 Content Too Large\r\n", buf.String())
+}
+
+func TestWriteInformational(t *testing.T) {
+	// Test: Early hints before the final response
+	buf := &bytes.Buffer{}
+	w := NewWriter(buf)
+	h := headers.NewHeaders()
+	h.Add("Link", "</style.css>; rel=preload; as=style")
+	h.Add("Link", "</app.js>; rel=preload; as=script")
+	require.NoError(t, w.WriteEarlyHints(h))
+	h = headers.NewHeaders()
+	h.Add("Link", "</font.woff2>; rel=preload; as=font")
+	require.NoError(t, w.WriteEarlyHints(h))
+	require.NoError(t, w.WriteInformational(StatusProcessing, nil))
+	fmt.Fprint(w, "page")
+	require.NoError(t, w.Finish())
+	assert.Equal(t, "HTTP/1.1 103 Early Hints\r\n"+
+		"Link: </style.css>; rel=preload; as=style\r\n"+
+		"Link: </app.js>; rel=preload; as=script\r\n"+
+		"\r\n"+
+		"HTTP/1.1 103 Early Hints\r\n"+
+		"Link: </font.woff2>; rel=preload; as=font\r\n"+
+		"\r\n"+
+		"HTTP/1.1 102 Processing\r\n"+
+		"\r\n"+
+		"HTTP/1.1 200 OK\r\nContent-Type: text/html\r\nContent-Length: 4\r\n\r\npage", buf.String())
+	assert.Equal(t, StatusOK, w.Status())
+
+	// Test: Refused after the final status
+	buf = &bytes.Buffer{}
+	w = NewWriter(buf)
+	fmt.Fprint(w, "early")
+	require.NoError(t, w.Flush())
+	assert.ErrorIs(t, w.WriteEarlyHints(nil), ErrWriteOrder)
+	assert.ErrorIs(t, w.WriteContinue(), ErrWriteOrder)
+
+	// Test: Only 1xx other than 101
+	buf = &bytes.Buffer{}
+	w = NewWriter(buf)
+	require.Error(t, w.WriteInformational(StatusOK, nil))
+	require.Error(t, w.WriteInformational(StatusSwitchingProtocols, nil))
+	require.Error(t, w.WriteInformational(99, nil))
+
+	// Test: Invalid header values
+	h = headers.NewHeaders()
+	h.Set("Link", "</a>\r\nX-Injected: 1")
+	require.Error(t, w.WriteEarlyHints(h))
+	assert.Empty(t, buf.String())
+
+	// Test: Nothing is sent to HTTP/1.0 clients
+	w.SetHttpVersion("1.0")
+	require.NoError(t, w.WriteEarlyHints(nil))
+	assert.Empty(t, buf.String())
+}
+
+func TestHijack(t *testing.T) {
+	// Test: No hijacker
+	w := NewWriter(&bytes.Buffer{})
+	_, _, err := w.Hijack()
+	require.Error(t, err)
+	assert.False(t, w.Hijacked())
+
+	// Test: Hijack hands over the connection and stops the writer
+	server, client := net.Pipe()
+	defer server.Close()
+	defer client.Close()
+	buf := &bytes.Buffer{}
+	w = NewWriter(buf)
+	w.SetHijacker(func() (net.Conn, []byte, error) {
+		return server, []byte("leftover"), nil
+	})
+	require.NoError(t, w.WriteStatusLine(StatusSwitchingProtocols))
+	w.Header().Set("X-Dropped", "1")
+	conn, buffered, err := w.Hijack()
+	require.NoError(t, err)
+	assert.Equal(t, server, conn)
+	assert.Equal(t, "leftover", string(buffered))
+	assert.True(t, w.Hijacked())
+	assert.Equal(t, StatusSwitchingProtocols, w.Status())
+
+	_, err = w.Write([]byte("x"))
+	assert.Error(t, err)
+	assert.ErrorIs(t, w.WriteHeaders(headers.NewHeaders()), ErrWriteOrder)
+	assert.ErrorIs(t, w.WriteContinue(), ErrWriteOrder)
+	require.NoError(t, w.Finish())
+	assert.Equal(t, "HTTP/1.1 101 Switching Protocols\r\n", buf.String())
+
+	// Test: Only once
+	_, _, err = w.Hijack()
+	assert.ErrorIs(t, err, ErrHijacked)
+}
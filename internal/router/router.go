@@ -0,0 +1,230 @@
+package router
+
+import (
+	"go-http-server/internal/request"
+	"go-http-server/internal/response"
+	"go-http-server/internal/server"
+	"net/url"
+	"slices"
+	"strings"
+)
+
+// WildcardParam is the name of the parameter that holds the rest of the
+// path matched by a trailing "*" segment.
+const WildcardParam = "*"
+
+// Router dispatches requests by method and path. Patterns are made of
+// "/"-separated segments, each one either literal, a "{name}" parameter
+// matching a single non-empty segment, or a final "*" matching the rest of
+// the path. Literal segments win over parameters, which win over wildcards.
+type Router struct {
+	root *node
+
+	// NotFound is called when no pattern matches the request path.
+	NotFound server.Handler
+}
+
+// Route is a handler registered for one method and pattern.
+type Route struct {
+	handler      server.Handler
+	maxBodyBytes int
+	hasBodyLimit bool
+}
+
+// MaxBodyBytes overrides the server body limit for requests to this
+// route. It is applied before the handler starts reading the body.
+func (rt *Route) MaxBodyBytes(n int) *Route {
+	rt.maxBodyBytes = n
+	rt.hasBodyLimit = true
+	return rt
+}
+
+type node struct {
+	static    map[string]*node
+	param     *node
+	paramName string
+	wildcard  *node
+	routes    map[string]*Route
+}
+
+type param struct {
+	name  string
+	value string
+}
+
+type match struct {
+	node   *node
+	params []param
+}
+
+func New() *Router {
+	return &Router{
+		root:     newNode(),
+		NotFound: notFound,
+	}
+}
+
+func newNode() *node {
+	return &node{
+		static: make(map[string]*node),
+		routes: make(map[string]*Route),
+	}
+}
+
+// Handle registers handler for method and pattern. It panics if the
+// pattern is malformed or already registered for method.
+func (r *Router) Handle(method string, pattern string, handler server.Handler) *Route {
+	if !strings.HasPrefix(pattern, "/") {
+		panic("router: pattern must begin with /: " + pattern)
+	}
+
+	n := r.root
+	segments := splitPath(pattern)
+	for i, seg := range segments {
+		switch {
+		case seg == "*":
+			if i != len(segments)-1 {
+				panic("router: wildcard must be the last segment: " + pattern)
+			}
+			if n.wildcard == nil {
+				n.wildcard = newNode()
+			}
+			n = n.wildcard
+
+		case strings.HasPrefix(seg, "{") && strings.HasSuffix(seg, "}"):
+			name := seg[1 : len(seg)-1]
+			if name == "" {
+				panic("router: empty parameter name: " + pattern)
+			}
+			if n.param == nil {
+				n.param = newNode()
+				n.param.paramName = name
+			} else if n.param.paramName != name {
+				panic("router: conflicting parameter name {" + name + "}: " + pattern)
+			}
+			n = n.param
+
+		default:
+			child, ok := n.static[seg]
+			if !ok {
+				child = newNode()
+				n.static[seg] = child
+			}
+			n = child
+		}
+	}
+
+	if _, ok := n.routes[method]; ok {
+		panic("router: duplicate route: " + method + " " + pattern)
+	}
+	rt := &Route{handler: handler}
+	n.routes[method] = rt
+	return rt
+}
+
+// Dispatch is a server.Handler that dispatches req to the matching route.
+// It answers 405 with an Allow header when the path matches but the
+// method does not.
+func (r *Router) Dispatch(w *response.Writer, req *request.Request) {
+	rt, params, matches := r.find(req)
+	if rt == nil {
+		if len(matches) == 0 {
+			r.NotFound(w, req)
+		} else {
+			methodNotAllowed(w, allowedMethods(matches))
+		}
+		return
+	}
+
+	req.Params = make(map[string]string, len(params))
+	for _, p := range params {
+		req.Params[p.name] = p.value
+	}
+	if rt.hasBodyLimit {
+		req.SetMaxBodyBytes(rt.maxBodyBytes)
+	}
+	rt.handler(w, req)
+}
+
+// find returns the route for req along with its captured parameters, and
+// every route whose pattern matches the path regardless of method.
+func (r *Router) find(req *request.Request) (*Route, []param, []match) {
+	path := req.RawPath()
+	if !strings.HasPrefix(path, "/") {
+		return nil, nil, nil
+	}
+
+	// Segments are split before decoding so that an escaped "/" stays part
+	// of its segment.
+	segments := splitPath(path)
+	for i, seg := range segments {
+		decoded, err := url.PathUnescape(seg)
+		if err != nil {
+			return nil, nil, nil
+		}
+		segments[i] = decoded
+	}
+
+	var matches []match
+	r.root.lookup(segments, nil, &matches)
+	for _, m := range matches {
+		if rt, ok := m.node.routes[req.RequestLine.Method]; ok {
+			return rt, m.params, matches
+		}
+	}
+	return nil, nil, matches
+}
+
+// lookup appends every route matching segments to matches, most specific
+// first.
+func (n *node) lookup(segments []string, params []param, matches *[]match) {
+	if len(segments) == 0 {
+		if len(n.routes) > 0 {
+			*matches = append(*matches, match{node: n, params: params})
+		}
+		return
+	}
+
+	seg := segments[0]
+	if child, ok := n.static[seg]; ok {
+		child.lookup(segments[1:], params, matches)
+	}
+	if n.param != nil && seg != "" {
+		p := append(slices.Clip(params), param{name: n.param.paramName, value: seg})
+		n.param.lookup(segments[1:], p, matches)
+	}
+	if n.wildcard != nil && len(n.wildcard.routes) > 0 {
+		p := append(slices.Clip(params), param{name: WildcardParam, value: strings.Join(segments, "/")})
+		*matches = append(*matches, match{node: n.wildcard, params: p})
+	}
+}
+
+func splitPath(path string) []string {
+	return strings.Split(path[1:], "/")
+}
+
+func allowedMethods(matches []match) []string {
+	methods := []string{}
+	for _, m := range matches {
+		for method := range m.node.routes {
+			if !slices.Contains(methods, method) {
+				methods = append(methods, method)
+			}
+		}
+	}
+	slices.Sort(methods)
+	return methods
+}
+
+func notFound(w *response.Writer, _ *request.Request) {
+	w.SetStatus(response.StatusNotFound)
+	w.Header().Set("content-type", "text/plain")
+	w.Write([]byte("404 Not Found\n"))
+}
+
+func methodNotAllowed(w *response.Writer, allowed []string) {
+	w.SetStatus(response.StatusMethodNotAllowed)
+	w.Header().Set("content-type", "text/plain")
+	w.Header().Set("allow", strings.Join(allowed, ", "))
+	w.Write([]byte("405 Method Not Allowed\n"))
+}
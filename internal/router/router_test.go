@@ -0,0 +1,160 @@
+package router
+
+import (
+	"bytes"
+	"go-http-server/internal/request"
+	"go-http-server/internal/response"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func serve(t *testing.T, r *Router, method string, target string) string {
+	req, err := request.RequestFromReader(strings.NewReader(method + " " + target + " HTTP/1.1\r\nHost: localhost\r\n\r\n"))
+	require.NoError(t, err)
+
+	buf := &bytes.Buffer{}
+	w := response.NewWriter(buf)
+	r.Dispatch(w, req)
+	require.NoError(t, w.Finish())
+	return buf.String()
+}
+
+func reply(body string) func(w *response.Writer, req *request.Request) {
+	return func(w *response.Writer, req *request.Request) {
+		b := body
+		for _, name := range []string{"id", "name", WildcardParam} {
+			if v, ok := req.Params[name]; ok {
+				b += " " + name + "=" + v
+			}
+		}
+		w.WriteStatusLine(response.StatusOK)
+		w.WriteHeaders(response.GetDefaultHeaders(len(b)))
+		w.WriteBody([]byte(b))
+	}
+}
+
+func TestRouterMatch(t *testing.T) {
+	r := New()
+	r.Handle("GET", "/", reply("root"))
+	r.Handle("GET", "/users", reply("list"))
+	r.Handle("GET", "/users/me", reply("me"))
+	r.Handle("GET", "/users/{id}", reply("user"))
+	r.Handle("POST", "/users/{id}", reply("update"))
+	r.Handle("GET", "/users/{id}/files/*", reply("files"))
+	r.Handle("GET", "/static/*", reply("static"))
+
+	// Test: Root
+	assert.True(t, strings.HasSuffix(serve(t, r, "GET", "/"), "\r\n\r\nroot"))
+
+	// Test: Literal path
+	assert.True(t, strings.HasSuffix(serve(t, r, "GET", "/users"), "\r\n\r\nlist"))
+
+	// Test: Literal segment wins over parameter
+	assert.True(t, strings.HasSuffix(serve(t, r, "GET", "/users/me"), "\r\n\r\nme"))
+
+	// Test: Parameter
+	assert.True(t, strings.HasSuffix(serve(t, r, "GET", "/users/42"), "\r\n\r\nuser id=42"))
+
+	// Test: Method matching
+	assert.True(t, strings.HasSuffix(serve(t, r, "POST", "/users/42"), "\r\n\r\nupdate id=42"))
+
+	// Test: Query string is ignored
+	assert.True(t, strings.HasSuffix(serve(t, r, "GET", "/users/42?x=1"), "\r\n\r\nuser id=42"))
+
+	// Test: Segments are percent-decoded
+	assert.True(t, strings.HasSuffix(serve(t, r, "GET", "/users/a%20b"), "\r\n\r\nuser id=a b"))
+
+	// Test: Escaped slash stays inside its segment
+	assert.True(t, strings.HasSuffix(serve(t, r, "GET", "/users/a%2Fb"), "\r\n\r\nuser id=a/b"))
+
+	// Test: Absolute-form target
+	assert.True(t, strings.HasSuffix(serve(t, r, "GET", "http://example.com/users/42"), "\r\n\r\nuser id=42"))
+
+	// Test: Asterisk-form target matches nothing
+	assert.True(t, strings.HasPrefix(serve(t, r, "OPTIONS", "*"), "HTTP/1.1 404 Not Found\r\n"))
+
+	// Test: Parameter and wildcard
+	assert.True(t, strings.HasSuffix(serve(t, r, "GET", "/users/7/files/a/b.txt"), "\r\n\r\nfiles id=7 *=a/b.txt"))
+
+	// Test: Wildcard matches empty remainder
+	assert.True(t, strings.HasSuffix(serve(t, r, "GET", "/static/"), "\r\n\r\nstatic *="))
+
+	// Test: Wildcard needs a segment after the prefix
+	assert.True(t, strings.HasPrefix(serve(t, r, "GET", "/static"), "HTTP/1.1 404 Not Found\r\n"))
+
+	// Test: Parameter does not match an empty segment
+	assert.True(t, strings.HasPrefix(serve(t, r, "GET", "/users//files/x"), "HTTP/1.1 404 Not Found\r\n"))
+
+	// Test: Unknown path
+	assert.True(t, strings.HasPrefix(serve(t, r, "GET", "/nope"), "HTTP/1.1 404 Not Found\r\n"))
+}
+
+func TestRouterMethodNotAllowed(t *testing.T) {
+	r := New()
+	r.Handle("GET", "/users/{id}", reply("user"))
+	r.Handle("PUT", "/users/{id}", reply("put"))
+	r.Handle("DELETE", "/users/*", reply("delete"))
+
+	// Test: 405 lists every method for the path
+	out := serve(t, r, "PATCH", "/users/42")
+	assert.True(t, strings.HasPrefix(out, "HTTP/1.1 405 Method Not Allowed\r\n"))
+	assert.Contains(t, out, "Allow: DELETE, GET, PUT\r\n")
+
+	// Test: A less specific route can serve the method
+	assert.True(t, strings.HasSuffix(serve(t, r, "DELETE", "/users/42"), "\r\n\r\ndelete *=42"))
+}
+
+func TestRouterNotFoundHandler(t *testing.T) {
+	r := New()
+	r.NotFound = reply("custom")
+	assert.True(t, strings.HasSuffix(serve(t, r, "GET", "/missing"), "\r\n\r\ncustom"))
+}
+
+func TestRouterHandlePanics(t *testing.T) {
+	r := New()
+	r.Handle("GET", "/users/{id}", reply("user"))
+
+	assert.Panics(t, func() { r.Handle("GET", "/users/{id}", reply("again")) })
+	assert.Panics(t, func() { r.Handle("GET", "/users/{name}/x", reply("conflict")) })
+	assert.Panics(t, func() { r.Handle("GET", "/a/*/b", reply("wildcard")) })
+	assert.Panics(t, func() { r.Handle("GET", "users", reply("relative")) })
+}
+
+func TestRouterLimits(t *testing.T) {
+	r := New()
+	bodyHandler := func(w *response.Writer, req *request.Request) {
+		body, err := req.ReadBody()
+		if err != nil {
+			w.WriteStatusLine(response.StatusContentTooLarge)
+			w.WriteHeaders(response.GetDefaultHeaders(0))
+			return
+		}
+		w.WriteStatusLine(response.StatusOK)
+		w.WriteHeaders(response.GetDefaultHeaders(len(body)))
+		w.WriteBody(body)
+	}
+	r.Handle("POST", "/upload/{name}", bodyHandler).MaxBodyBytes(1 << 30)
+	r.Handle("POST", "/small", bodyHandler)
+
+	serveBody := func(target string) string {
+		reader := request.NewReader(strings.NewReader("POST " + target + " HTTP/1.1\r\nHost: localhost\r\nContent-Length: 11\r\n\r\nhello world"))
+		reader.Limits = request.Limits{MaxBodyBytes: 5}
+		req, err := reader.ReadHeaders()
+		require.NoError(t, err)
+
+		buf := &bytes.Buffer{}
+		w := response.NewWriter(buf)
+		r.Dispatch(w, req)
+		require.NoError(t, w.Finish())
+		return buf.String()
+	}
+
+	// Test: Route raises the body limit
+	assert.True(t, strings.HasSuffix(serveBody("/upload/a"), "\r\n\r\nhello world"))
+
+	// Test: Route without a limit keeps the server limit
+	assert.True(t, strings.HasPrefix(serveBody("/small"), "HTTP/1.1 413 Content Too Large\r\n"))
+}
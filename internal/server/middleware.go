@@ -0,0 +1,36 @@
+package server
+
+import (
+	"go-http-server/internal/request"
+	"go-http-server/internal/response"
+	"log"
+	"time"
+)
+
+// Middleware wraps a Handler with behaviour that runs before and after it.
+type Middleware func(Handler) Handler
+
+// Chain wraps handler with middleware so that the first middleware is the
+// outermost one and runs first.
+func Chain(handler Handler, middleware ...Middleware) Handler {
+	for i := len(middleware) - 1; i >= 0; i-- {
+		handler = middleware[i](handler)
+	}
+	return handler
+}
+
+// Logger logs the method, target, status, body size and duration of every
+// request once the handler returns.
+func Logger(next Handler) Handler {
+	return func(w *response.Writer, req *request.Request) {
+		start := time.Now()
+		next(w, req)
+		log.Printf("%s %s %d %dB %s",
+			req.RequestLine.Method,
+			req.RequestLine.RequestTarget,
+			w.Status(),
+			w.BytesWritten(),
+			time.Since(start),
+		)
+	}
+}
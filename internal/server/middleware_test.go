@@ -0,0 +1,98 @@
+package server
+
+import (
+	"bytes"
+	"go-http-server/internal/request"
+	"go-http-server/internal/response"
+	"log"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newRequest(t *testing.T) *request.Request {
+	req, err := request.RequestFromReader(strings.NewReader("GET /path HTTP/1.1\r\nHost: localhost\r\n\r\n"))
+	require.NoError(t, err)
+	return req
+}
+
+func TestChain(t *testing.T) {
+	var calls []string
+	record := func(name string) Middleware {
+		return func(next Handler) Handler {
+			return func(w *response.Writer, req *request.Request) {
+				calls = append(calls, name+" before")
+				next(w, req)
+				calls = append(calls, name+" after")
+			}
+		}
+	}
+
+	// Test: First middleware is the outermost
+	handler := Chain(func(w *response.Writer, req *request.Request) {
+		calls = append(calls, "handler")
+	}, record("a"), record("b"))
+	handler(response.NewWriter(&bytes.Buffer{}), newRequest(t))
+	assert.Equal(t, []string{"a before", "b before", "handler", "b after", "a after"}, calls)
+
+	// Test: No middleware
+	calls = nil
+	Chain(func(w *response.Writer, req *request.Request) {
+		calls = append(calls, "handler")
+	})(response.NewWriter(&bytes.Buffer{}), newRequest(t))
+	assert.Equal(t, []string{"handler"}, calls)
+}
+
+func TestMiddlewareSeesResponse(t *testing.T) {
+	var status response.StatusCode
+	var bytesWritten int
+	observe := func(next Handler) Handler {
+		return func(w *response.Writer, req *request.Request) {
+			next(w, req)
+			status = w.Status()
+			bytesWritten = w.BytesWritten()
+		}
+	}
+
+	// Test: Direct writes
+	w := response.NewWriter(&bytes.Buffer{})
+	Chain(func(w *response.Writer, req *request.Request) {
+		w.WriteStatusLine(response.StatusNotFound)
+		w.WriteHeaders(response.GetDefaultHeaders(9))
+		w.WriteBody([]byte("not found"))
+	}, observe)(w, newRequest(t))
+	assert.Equal(t, response.StatusNotFound, status)
+	assert.Equal(t, 9, bytesWritten)
+
+	// Test: Buffered writes before Finish
+	w = response.NewWriter(&bytes.Buffer{})
+	Chain(func(w *response.Writer, req *request.Request) {
+		w.SetStatus(response.StatusCreated)
+		w.Write([]byte("created"))
+	}, observe)(w, newRequest(t))
+	assert.Equal(t, response.StatusCreated, status)
+	assert.Equal(t, 7, bytesWritten)
+
+	// Test: Buffered write with the default status
+	w = response.NewWriter(&bytes.Buffer{})
+	Chain(func(w *response.Writer, req *request.Request) {
+		w.Write([]byte("ok"))
+	}, observe)(w, newRequest(t))
+	assert.Equal(t, response.StatusOK, status)
+	assert.Equal(t, 2, bytesWritten)
+}
+
+func TestLogger(t *testing.T) {
+	buf := &bytes.Buffer{}
+	defer log.SetOutput(log.Writer())
+	log.SetOutput(buf)
+
+	// Test: Method, target, status and size are logged
+	Logger(func(w *response.Writer, req *request.Request) {
+		w.SetStatus(response.StatusAccepted)
+		w.Write([]byte("queued"))
+	})(response.NewWriter(&bytes.Buffer{}), newRequest(t))
+	assert.Contains(t, buf.String(), "GET /path 202 6B ")
+}
@@ -0,0 +1,66 @@
+package server
+
+import (
+	"go-http-server/internal/request"
+	"time"
+)
+
+// Option configures a Server created by Serve.
+type Option func(*Server)
+
+// WithReadHeaderTimeout limits the time allowed to read the request line
+// and headers. Clients that miss it get a 408 Request Timeout.
+func WithReadHeaderTimeout(d time.Duration) Option {
+	return func(s *Server) {
+		s.readHeaderTimeout = d
+	}
+}
+
+// WithReadTimeout limits the time allowed to read the request body once
+// the headers have been read.
+func WithReadTimeout(d time.Duration) Option {
+	return func(s *Server) {
+		s.readTimeout = d
+	}
+}
+
+// WithWriteTimeout limits the time allowed to write the response, starting
+// when the request headers have been read.
+func WithWriteTimeout(d time.Duration) Option {
+	return func(s *Server) {
+		s.writeTimeout = d
+	}
+}
+
+// WithIdleTimeout limits how long a keep-alive connection may wait for the
+// next request. It defaults to the read header timeout.
+func WithIdleTimeout(d time.Duration) Option {
+	return func(s *Server) {
+		s.idleTimeout = d
+	}
+}
+
+// WithLimits sets the request size limits. Servers use
+// request.DefaultLimits unless this option is given.
+func WithLimits(limits request.Limits) Option {
+	return func(s *Server) {
+		s.limits = limits
+	}
+}
+
+// WithSortedHeaders writes response header fields sorted by name instead of
+// in the order the handler added them.
+func WithSortedHeaders() Option {
+	return func(s *Server) {
+		s.sortHeaders = true
+	}
+}
+
+// WithLenientHeaders accepts obsolete line folding in request headers from
+// legacy clients, unfolding it into a single space. By default such
+// requests get a 400 Bad Request.
+func WithLenientHeaders() Option {
+	return func(s *Server) {
+		s.lenientHeaders = true
+	}
+}
@@ -1,25 +1,125 @@
 package server
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"go-http-server/internal/request"
 	"go-http-server/internal/response"
+	"io"
 	"log"
 	"net"
+	"sync"
 	"sync/atomic"
+	"time"
 )
 
 type Handler func(w *response.Writer, req *request.Request)
 
+type connState int
+
+const (
+	// stateIdle means the connection is waiting for the first byte of a
+	// new request.
+	stateIdle connState = iota
+	stateActive
+)
+
+const shutdownPollInterval = 50 * time.Millisecond
+
 type Server struct {
 	listener net.Listener
 	isClosed atomic.Bool
 	handler  Handler
+
+	mu    sync.Mutex
+	conns map[net.Conn]connState
+
+	readHeaderTimeout time.Duration
+	readTimeout       time.Duration
+	writeTimeout      time.Duration
+	idleTimeout       time.Duration
+
+	limits         request.Limits
+	lenientHeaders bool
+	sortHeaders    bool
 }
 
+// Close stops accepting connections and closes every open connection
+// immediately. Use Shutdown to let in-flight requests finish.
 func (s *Server) Close() error {
 	s.isClosed.Store(true)
-	return s.listener.Close()
+	err := s.listener.Close()
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for conn := range s.conns {
+		conn.Close()
+	}
+
+	return err
+}
+
+// Shutdown stops accepting connections, closes idle ones and waits for
+// active ones to finish their current request. If ctx expires first, the
+// remaining connections are closed and ctx.Err() is returned.
+func (s *Server) Shutdown(ctx context.Context) error {
+	s.isClosed.Store(true)
+	err := s.listener.Close()
+
+	ticker := time.NewTicker(shutdownPollInterval)
+	defer ticker.Stop()
+	for {
+		if s.closeIdleConns() {
+			return err
+		}
+		select {
+		case <-ctx.Done():
+			s.Close()
+			return ctx.Err()
+		case <-ticker.C:
+		}
+	}
+}
+
+// closeIdleConns reports whether no connections remain afterwards.
+func (s *Server) closeIdleConns() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for conn, state := range s.conns {
+		if state == stateIdle {
+			conn.Close()
+			delete(s.conns, conn)
+		}
+	}
+	return len(s.conns) == 0
+}
+
+func (s *Server) setState(conn net.Conn, state connState) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.conns[conn]; ok {
+		s.conns[conn] = state
+	}
+}
+
+// trackConn reports false if the server is already shutting down, in
+// which case conn must not be served. Checking under s.mu keeps Shutdown
+// from missing a connection accepted just before it started.
+func (s *Server) trackConn(conn net.Conn) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.isClosed.Load() {
+		return false
+	}
+	s.conns[conn] = stateIdle
+	return true
+}
+
+func (s *Server) untrackConn(conn net.Conn) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.conns, conn)
 }
 
 func (s *Server) listen() {
@@ -32,25 +132,205 @@ func (s *Server) listen() {
 			log.Println("Accept error:", err)
 			continue
 		}
+		if !s.trackConn(conn) {
+			conn.Close()
+			return
+		}
 		go s.handle(conn)
 	}
 }
 
+// activityReader marks its connection active as soon as a byte arrives.
+// A connection waiting for its next request is idle and uses the idle
+// timeout; the first byte of the request switches to the header timeout.
+type activityReader struct {
+	server *Server
+	conn   net.Conn
+	idle   bool
+}
+
+func (r *activityReader) Read(p []byte) (int, error) {
+	n, err := r.conn.Read(p)
+	if n > 0 {
+		r.server.setState(r.conn, stateActive)
+		if r.idle {
+			r.idle = false
+			r.conn.SetReadDeadline(deadline(r.server.readHeaderTimeout))
+		}
+	}
+	return n, err
+}
+
+// handle serves requests from conn one at a time. Pipelined requests are
+// read only after the previous response has been written, so responses
+// always go out in request order.
 func (s *Server) handle(conn net.Conn) {
-	defer conn.Close()
+	hijacked := false
+	defer func() {
+		if !hijacked {
+			conn.Close()
+			s.untrackConn(conn)
+		}
+	}()
 
-	w := response.NewWriter(conn)
-	req, err := request.RequestFromReader(conn)
-	if err != nil {
-		w.WriteStatusLine(response.StatusBadRequest)
-		w.WriteHeaders(response.GetDefaultHeaders(0))
-		return
+	ar := &activityReader{server: s, conn: conn}
+	reader := request.NewReader(ar)
+	reader.Limits = s.limits
+	reader.LenientHeaders = s.lenientHeaders
+	for first := true; ; first = false {
+		if reader.Buffered() == 0 {
+			if s.isClosed.Load() {
+				return
+			}
+			s.setState(conn, stateIdle)
+		}
+
+		if first || reader.Buffered() > 0 {
+			conn.SetReadDeadline(deadline(s.readHeaderTimeout))
+		} else {
+			ar.idle = true
+			conn.SetReadDeadline(deadline(s.idleTimeoutOrDefault()))
+		}
+
+		w := response.NewWriter(conn)
+		w.SetSortHeaders(s.sortHeaders)
+		w.SetHijacker(func() (net.Conn, []byte, error) {
+			hijacked = true
+			s.untrackConn(conn)
+			conn.SetDeadline(time.Time{})
+			return conn, reader.TakeBuffered(), nil
+		})
+		req, err := reader.ReadHeaders()
+		if err != nil {
+			if !errors.Is(err, io.EOF) && !(isTimeout(err) && ar.idle) {
+				s.writeError(conn, w, err)
+			}
+			return
+		}
+
+		conn.SetReadDeadline(deadline(s.readTimeout))
+		conn.SetWriteDeadline(deadline(s.writeTimeout))
+
+		w.SetHttpVersion(req.RequestLine.HttpVersion)
+		keepAlive := req.KeepAlive() && !s.isClosed.Load()
+
+		// While the client holds back its body for 100 Continue, that body
+		// cannot be told apart from the next request. A final response
+		// sent before the body is asked for must therefore close the
+		// connection; sending 100 Continue lifts this.
+		w.SetKeepAlive(keepAlive && !req.ExpectsContinue())
+
+		body := &bodyTracker{ReadCloser: req.Body}
+		req.Body = body
+		req.OnContinue(func() {
+			if w.WriteContinue() == nil {
+				w.SetKeepAlive(keepAlive)
+			}
+		})
+
+		s.handler(w, req)
+		if hijacked {
+			return
+		}
+
+		if w.Status() == 0 {
+			if body.err != nil {
+				s.writeError(conn, w, body.err)
+				return
+			}
+			w.WriteStatusLine(response.StatusInternalServerError)
+		}
+		if err := w.Finish(); err != nil {
+			// A response rejected before it reached the wire, such as
+			// one with an invalid header value, can still become a 500.
+			// Once the status line is out, closing is all that is left.
+			if !w.Started() {
+				log.Println("Response error:", err)
+				s.writeStatus(conn, w, response.StatusInternalServerError)
+			} else if errors.Is(err, response.ErrResponseFailed) {
+				log.Println("Response error:", err)
+			}
+			return
+		}
+
+		if !w.KeepAlive() {
+			return
+		}
+		if err := body.Close(); err != nil {
+			return
+		}
+	}
+}
+
+// idleTimeoutOrDefault falls back to the read header timeout when no idle
+// timeout is set.
+func (s *Server) idleTimeoutOrDefault() time.Duration {
+	if s.idleTimeout == 0 {
+		return s.readHeaderTimeout
+	}
+	return s.idleTimeout
+}
+
+// bodyTracker remembers the first error a handler got while reading the
+// request body, so the server can still answer with the matching status.
+type bodyTracker struct {
+	io.ReadCloser
+	err error
+}
+
+func (b *bodyTracker) Read(p []byte) (int, error) {
+	n, err := b.ReadCloser.Read(p)
+	if err != nil && !errors.Is(err, io.EOF) && b.err == nil {
+		b.err = err
 	}
+	return n, err
+}
+
+// writeError answers a request that could not be read. The connection is
+// always closed afterwards.
+func (s *Server) writeError(conn net.Conn, w *response.Writer, err error) {
+	status := response.StatusBadRequest
+	switch {
+	case isTimeout(err):
+		status = response.StatusRequestTimeout
+	case errors.Is(err, request.ErrRequestLineTooLong):
+		status = response.StatusURITooLong
+	case errors.Is(err, request.ErrHeadersTooLarge):
+		status = response.StatusRequestHeaderFieldsTooLarge
+	case errors.Is(err, request.ErrBodyTooLarge):
+		status = response.StatusContentTooLarge
+	case errors.Is(err, request.ErrVersionNotSupported):
+		status = response.StatusHTTPVersionNotSupported
+	case errors.Is(err, request.ErrExpectationFailed):
+		status = response.StatusExpectationFailed
+	case errors.Is(err, request.ErrTransferCodingNotImplemented):
+		status = response.StatusNotImplemented
+	}
+	s.writeStatus(conn, w, status)
+}
+
+// writeStatus sends an empty response with status and Connection: close.
+func (s *Server) writeStatus(conn net.Conn, w *response.Writer, status response.StatusCode) {
+	conn.SetWriteDeadline(deadline(s.writeTimeout))
+	w.SetKeepAlive(false)
+	w.WriteStatusLine(status)
+	w.WriteHeaders(response.GetDefaultHeaders(0))
+}
 
-	s.handler(w, req)
+func isTimeout(err error) bool {
+	var netErr net.Error
+	return errors.As(err, &netErr) && netErr.Timeout()
 }
 
-func Serve(port int, handler Handler) (*Server, error) {
+// deadline returns the zero time, meaning no deadline, when d is 0.
+func deadline(d time.Duration) time.Time {
+	if d == 0 {
+		return time.Time{}
+	}
+	return time.Now().Add(d)
+}
+
+func Serve(port int, handler Handler, opts ...Option) (*Server, error) {
 	ln, err := net.Listen("tcp", fmt.Sprintf(":%d", port))
 	if err != nil {
 		return nil, err
@@ -59,6 +339,11 @@ func Serve(port int, handler Handler) (*Server, error) {
 	server := &Server{
 		listener: ln,
 		handler:  handler,
+		conns:    make(map[net.Conn]connState),
+		limits:   request.DefaultLimits,
+	}
+	for _, opt := range opts {
+		opt(server)
 	}
 
 	go server.listen()
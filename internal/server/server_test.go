@@ -0,0 +1,394 @@
+package server
+
+import (
+	"bufio"
+	"context"
+	"go-http-server/internal/request"
+	"go-http-server/internal/response"
+	"io"
+	"net"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// startServer serves handler on a free loopback port and returns its
+// address.
+func startServer(t *testing.T, handler Handler, opts ...Option) (*Server, string) {
+	s, err := Serve(0, handler, opts...)
+	require.NoError(t, err)
+	t.Cleanup(func() { s.Close() })
+	return s, s.listener.Addr().String()
+}
+
+func dial(t *testing.T, addr string) net.Conn {
+	conn, err := net.Dial("tcp", addr)
+	require.NoError(t, err)
+	t.Cleanup(func() { conn.Close() })
+	conn.SetDeadline(time.Now().Add(5 * time.Second))
+	return conn
+}
+
+func readResponse(t *testing.T, r *bufio.Reader) (*http.Response, string) {
+	resp, err := http.ReadResponse(r, nil)
+	require.NoError(t, err)
+	body, err := io.ReadAll(resp.Body)
+	require.NoError(t, err)
+	return resp, string(body)
+}
+
+func reply(body string) Handler {
+	return func(w *response.Writer, req *request.Request) {
+		w.WriteStatusLine(response.StatusOK)
+		w.WriteHeaders(response.GetDefaultHeaders(len(body)))
+		w.WriteBody([]byte(body))
+	}
+}
+
+const getRequest = "GET / HTTP/1.1\r\nHost: localhost\r\n\r\n"
+
+func TestTimeouts(t *testing.T) {
+	// Test: Slow request headers get a 408
+	_, addr := startServer(t, reply("x"), WithReadHeaderTimeout(100*time.Millisecond))
+	conn := dial(t, addr)
+	conn.Write([]byte("GET / HTTP/1.1\r\nHost: loc"))
+	out, err := io.ReadAll(conn)
+	require.NoError(t, err)
+	assert.True(t, strings.HasPrefix(string(out), "HTTP/1.1 408 Request Timeout\r\n"), string(out))
+
+	// Test: Idle keep-alive connection closes silently under the default idle timeout
+	conn = dial(t, addr)
+	conn.Write([]byte(getRequest))
+	reader := bufio.NewReader(conn)
+	resp, body := readResponse(t, reader)
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Equal(t, "x", body)
+	rest, err := io.ReadAll(reader)
+	require.NoError(t, err)
+	assert.Empty(t, string(rest))
+
+	// Test: Idle timeout closes silently
+	_, addr = startServer(t, reply("x"), WithReadHeaderTimeout(5*time.Second), WithIdleTimeout(100*time.Millisecond))
+	conn = dial(t, addr)
+	conn.Write([]byte(getRequest))
+	reader = bufio.NewReader(conn)
+	readResponse(t, reader)
+	start := time.Now()
+	rest, err = io.ReadAll(reader)
+	require.NoError(t, err)
+	assert.Empty(t, string(rest))
+	assert.Less(t, time.Since(start), 2*time.Second)
+
+	// Test: Slow request body gets a 408
+	_, addr = startServer(t, func(w *response.Writer, req *request.Request) {
+		io.ReadAll(req.Body)
+	}, WithReadTimeout(100*time.Millisecond))
+	conn = dial(t, addr)
+	conn.Write([]byte("POST / HTTP/1.1\r\nHost: localhost\r\nContent-Length: 10\r\n\r\nabc"))
+	out, err = io.ReadAll(conn)
+	require.NoError(t, err)
+	assert.True(t, strings.HasPrefix(string(out), "HTTP/1.1 408 Request Timeout\r\n"), string(out))
+}
+
+func TestHandlerWritesNothing(t *testing.T) {
+	// Test: Setting headers alone gets a 500
+	_, addr := startServer(t, func(w *response.Writer, req *request.Request) {
+		w.Header().Set("x-request-id", "1")
+	})
+	conn := dial(t, addr)
+	conn.Write([]byte(getRequest))
+	resp, _ := readResponse(t, bufio.NewReader(conn))
+	assert.Equal(t, http.StatusInternalServerError, resp.StatusCode)
+}
+
+func TestInvalidResponseHeader(t *testing.T) {
+	// Test: A buffered response rejected before it is sent becomes a 500
+	_, addr := startServer(t, func(w *response.Writer, req *request.Request) {
+		w.Header().Set("x-note", "a\r\nInjected: 1")
+		w.Write([]byte("hello"))
+	})
+	conn := dial(t, addr)
+	conn.Write([]byte(getRequest))
+	out, err := io.ReadAll(conn)
+	require.NoError(t, err)
+	assert.True(t, strings.HasPrefix(string(out), "HTTP/1.1 500 Internal Server Error\r\n"), string(out))
+	assert.NotContains(t, string(out), "Injected")
+	assert.NotContains(t, string(out), "hello")
+
+	// Test: Headers rejected after a direct status line close the connection
+	_, addr = startServer(t, func(w *response.Writer, req *request.Request) {
+		w.WriteStatusLine(response.StatusOK)
+		h := response.GetDefaultHeaders(0)
+		h.Set("x-user", "a\r\nSet-Cookie: evil=1")
+		w.WriteHeaders(h)
+	})
+	conn = dial(t, addr)
+	conn.Write([]byte(getRequest))
+	out, err = io.ReadAll(conn)
+	require.NoError(t, err)
+	assert.Equal(t, "HTTP/1.1 200 OK\r\n", string(out))
+}
+
+func TestShutdown(t *testing.T) {
+	entered := make(chan struct{})
+	release := make(chan struct{})
+	slow := func(w *response.Writer, req *request.Request) {
+		entered <- struct{}{}
+		<-release
+		reply("done")(w, req)
+	}
+
+	// Test: In-flight request completes
+	s, addr := startServer(t, slow)
+	conn := dial(t, addr)
+	conn.Write([]byte(getRequest))
+	<-entered
+	shutdownErr := make(chan error)
+	go func() {
+		shutdownErr <- s.Shutdown(context.Background())
+	}()
+	time.Sleep(2 * shutdownPollInterval)
+	release <- struct{}{}
+	reader := bufio.NewReader(conn)
+	resp, body := readResponse(t, reader)
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Equal(t, "done", body)
+	require.NoError(t, <-shutdownErr)
+	rest, err := io.ReadAll(reader)
+	require.NoError(t, err)
+	assert.Empty(t, rest)
+
+	// Test: Idle keep-alive connection is closed
+	s, addr = startServer(t, reply("x"))
+	conn = dial(t, addr)
+	conn.Write([]byte(getRequest))
+	reader = bufio.NewReader(conn)
+	readResponse(t, reader)
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+	require.NoError(t, s.Shutdown(ctx))
+	rest, err = io.ReadAll(reader)
+	require.NoError(t, err)
+	assert.Empty(t, rest)
+
+	// Test: Expired context force-closes active connections
+	s, addr = startServer(t, slow)
+	conn = dial(t, addr)
+	conn.Write([]byte(getRequest))
+	<-entered
+	ctx, cancel = context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	assert.ErrorIs(t, s.Shutdown(ctx), context.DeadlineExceeded)
+	rest, _ = io.ReadAll(conn)
+	assert.Empty(t, rest)
+	release <- struct{}{}
+
+	// Test: Hijacked connections are not waited on
+	hijacked := make(chan net.Conn, 1)
+	s, addr = startServer(t, func(w *response.Writer, req *request.Request) {
+		c, _, err := w.Hijack()
+		require.NoError(t, err)
+		hijacked <- c
+	})
+	conn = dial(t, addr)
+	conn.Write([]byte(getRequest))
+	serverConn := <-hijacked
+	defer serverConn.Close()
+	ctx, cancel = context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+	require.NoError(t, s.Shutdown(ctx))
+	serverConn.Write([]byte("still open"))
+	buf := make([]byte, len("still open"))
+	_, err = io.ReadFull(conn, buf)
+	require.NoError(t, err)
+	assert.Equal(t, "still open", string(buf))
+
+	// Test: A connection accepted while shutting down is not tracked
+	s, _ = startServer(t, reply("x"))
+	require.NoError(t, s.Shutdown(context.Background()))
+	server, client := net.Pipe()
+	defer client.Close()
+	defer server.Close()
+	assert.False(t, s.trackConn(server))
+	assert.Empty(t, s.conns)
+}
+
+func TestPipelining(t *testing.T) {
+	// Test: Responses come back in request order, with unread bodies drained
+	_, addr := startServer(t, func(w *response.Writer, req *request.Request) {
+		if req.Path() == "/two" {
+			body, _ := io.ReadAll(req.Body)
+			w.Write([]byte("two:" + string(body)))
+			return
+		}
+		w.Write([]byte(req.Path()))
+	})
+	conn := dial(t, addr)
+	conn.Write([]byte("POST /one HTTP/1.1\r\nHost: localhost\r\nContent-Length: 5\r\n\r\nhello" +
+		"POST /two HTTP/1.1\r\nHost: localhost\r\nTransfer-Encoding: chunked\r\n\r\n5\r\nworld\r\n0\r\n\r\n" +
+		"POST /three HTTP/1.1\r\nHost: localhost\r\nTransfer-Encoding: chunked\r\n\r\n3\r\nabc\r\n0\r\n\r\n" +
+		"GET /four HTTP/1.1\r\nHost: localhost\r\nConnection: close\r\n\r\n"))
+	reader := bufio.NewReader(conn)
+	for _, want := range []string{"/one", "two:world", "/three", "/four"} {
+		resp, body := readResponse(t, reader)
+		assert.Equal(t, http.StatusOK, resp.StatusCode)
+		assert.Equal(t, want, body)
+	}
+	rest, err := io.ReadAll(reader)
+	require.NoError(t, err)
+	assert.Empty(t, rest)
+}
+
+func TestKeepAlive(t *testing.T) {
+	_, addr := startServer(t, func(w *response.Writer, req *request.Request) {
+		if req.Path() == "/close" {
+			w.Header().Set("connection", "close")
+		}
+		if req.Path() == "/keep" {
+			w.Header().Set("connection", "keep-alive")
+		}
+		w.Write([]byte(req.Path()))
+	})
+
+	// Test: Requests are served one after another on the same connection
+	conn := dial(t, addr)
+	reader := bufio.NewReader(conn)
+	for _, path := range []string{"/a", "/b", "/c"} {
+		conn.Write([]byte("GET " + path + " HTTP/1.1\r\nHost: localhost\r\n\r\n"))
+		resp, body := readResponse(t, reader)
+		assert.Equal(t, path, body)
+		assert.False(t, resp.Close)
+	}
+
+	for _, test := range []struct {
+		name    string
+		request string
+	}{
+		{"client sends Connection: close", "GET /a HTTP/1.1\r\nHost: localhost\r\nConnection: close\r\n\r\n"},
+		{"handler sends Connection: close", "GET /close HTTP/1.1\r\nHost: localhost\r\n\r\n"},
+		{"HTTP/1.0 without keep-alive", "GET /a HTTP/1.0\r\n\r\n"},
+		{"handler keep-alive overridden for HTTP/1.0", "GET /keep HTTP/1.0\r\n\r\n"},
+	} {
+		// Test: Each of these ends the connection after the response
+		conn := dial(t, addr)
+		conn.Write([]byte(test.request))
+		reader := bufio.NewReader(conn)
+		resp, _ := readResponse(t, reader)
+		assert.True(t, resp.Close, test.name)
+		rest, err := io.ReadAll(reader)
+		require.NoError(t, err, test.name)
+		assert.Empty(t, rest, test.name)
+	}
+
+	// Test: HTTP/1.0 client asking for keep-alive
+	conn = dial(t, addr)
+	reader = bufio.NewReader(conn)
+	for range 2 {
+		conn.Write([]byte("GET /a HTTP/1.0\r\nConnection: keep-alive\r\n\r\n"))
+		resp, body := readResponse(t, reader)
+		assert.Equal(t, "/a", body)
+		assert.Equal(t, "keep-alive", resp.Header.Get("Connection"))
+	}
+}
+
+func TestExpectContinue(t *testing.T) {
+	_, addr := startServer(t, func(w *response.Writer, req *request.Request) {
+		if req.Path() == "/reject" {
+			w.WriteStatusLine(response.StatusUnauthorized)
+			w.WriteHeaders(response.GetDefaultHeaders(2))
+			w.WriteBody([]byte("no"))
+			return
+		}
+		body, _ := io.ReadAll(req.Body)
+		w.Write([]byte("got:" + string(body)))
+	})
+	expectHead := func(path string) string {
+		return "POST " + path + " HTTP/1.1\r\nHost: localhost\r\nContent-Length: 5\r\nExpect: 100-continue\r\n\r\n"
+	}
+
+	// Test: 100 Continue goes out before the body is sent
+	conn := dial(t, addr)
+	conn.Write([]byte(expectHead("/upload")))
+	reader := bufio.NewReader(conn)
+	line, err := reader.ReadString('\n')
+	require.NoError(t, err)
+	assert.Equal(t, "HTTP/1.1 100 Continue\r\n", line)
+	line, err = reader.ReadString('\n')
+	require.NoError(t, err)
+	assert.Equal(t, "\r\n", line)
+	conn.Write([]byte("hello"))
+	resp, body := readResponse(t, reader)
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Equal(t, "got:hello", body)
+	assert.False(t, resp.Close)
+
+	// Test: The connection stays usable after the body was sent
+	conn.Write([]byte(getRequest))
+	_, body = readResponse(t, reader)
+	assert.Equal(t, "got:", body)
+
+	// Test: Final response without reading the body closes the connection
+	conn = dial(t, addr)
+	conn.Write([]byte(expectHead("/reject")))
+	reader = bufio.NewReader(conn)
+	resp, body = readResponse(t, reader)
+	assert.Equal(t, http.StatusUnauthorized, resp.StatusCode)
+	assert.Equal(t, "no", body)
+	assert.True(t, resp.Close)
+	rest, err := io.ReadAll(reader)
+	require.NoError(t, err)
+	assert.Empty(t, rest)
+
+	// Test: Unknown expectation gets a 417
+	conn = dial(t, addr)
+	conn.Write([]byte("POST / HTTP/1.1\r\nHost: localhost\r\nContent-Length: 5\r\nExpect: something\r\n\r\n"))
+	reader = bufio.NewReader(conn)
+	resp, _ = readResponse(t, reader)
+	assert.Equal(t, http.StatusExpectationFailed, resp.StatusCode)
+	assert.True(t, resp.Close)
+}
+
+func TestBadRequests(t *testing.T) {
+	_, addr := startServer(t, func(w *response.Writer, req *request.Request) {
+		if _, err := io.ReadAll(req.Body); err != nil {
+			return
+		}
+		w.Write([]byte("ok"))
+	}, WithLimits(request.Limits{
+		MaxRequestLineBytes: 30,
+		MaxHeaderBytes:      1 << 10,
+		MaxHeaderCount:      3,
+		MaxBodyBytes:        10,
+	}))
+
+	// Each request ends where the server detects the error, so that the
+	// connection has no unread bytes left when it is closed.
+	for _, test := range []struct {
+		name    string
+		request string
+		status  string
+	}{
+		{"request line too long", "GET /" + strings.Repeat("a", 40) + " HTTP/1.1\r\n", "414 URI Too Long"},
+		{"too many header fields", "GET / HTTP/1.1\r\nA: 1\r\nB: 2\r\nC: 3\r\nD: 4\r\n", "431 Request Header Fields Too Large"},
+		{"body too large", "POST / HTTP/1.1\r\nHost: localhost\r\nContent-Length: 11\r\n\r\n", "413 Content Too Large"},
+		{"unsupported version", "GET / HTTP/2.0\r\n", "505 HTTP Version Not Supported"},
+		{"malformed request line", "GET /\r\n", "400 Bad Request"},
+		{"missing Host", "GET / HTTP/1.1\r\n\r\n", "400 Bad Request"},
+		{"Transfer-Encoding with Content-Length", "POST / HTTP/1.1\r\nHost: localhost\r\nTransfer-Encoding: chunked\r\nContent-Length: 5\r\n\r\n", "400 Bad Request"},
+		{"conflicting Content-Length", "POST / HTTP/1.1\r\nHost: localhost\r\nContent-Length: 5\r\nContent-Length: 6\r\n\r\n", "400 Bad Request"},
+		{"chunked not last", "POST / HTTP/1.1\r\nHost: localhost\r\nTransfer-Encoding: chunked, gzip\r\n\r\n", "400 Bad Request"},
+		{"unknown transfer coding", "POST / HTTP/1.1\r\nHost: localhost\r\nTransfer-Encoding: gzip, chunked\r\n\r\n", "501 Not Implemented"},
+	} {
+		// Test: Each bad request gets its status and closes the connection
+		conn := dial(t, addr)
+		conn.Write([]byte(test.request))
+		out, err := io.ReadAll(conn)
+		require.NoError(t, err, test.name)
+		assert.True(t, strings.HasPrefix(string(out), "HTTP/1.1 "+test.status+"\r\n"), "%s: %q", test.name, out)
+		assert.Contains(t, string(out), "\r\nConnection: close\r\n", test.name)
+	}
+}
@@ -4,6 +4,7 @@ const (
 	HTTPVersionPrefix      = "HTTP/"
 	CRLF                   = "\r\n"
 	SP                byte = ' '
+	HTAB              byte = '\t'
 	COLON             byte = ':'
 	CR                byte = '\r'
 	LF                byte = '\n'
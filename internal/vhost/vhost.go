@@ -0,0 +1,92 @@
+package vhost
+
+import (
+	"go-http-server/internal/request"
+	"go-http-server/internal/response"
+	"go-http-server/internal/server"
+	"net"
+	"strings"
+)
+
+// Dispatcher picks a handler by the host a request is for. Patterns are
+// either a hostname such as "example.com" or a wildcard such as
+// "*.example.com", which matches any subdomain of example.com but not
+// example.com itself. Exact hostnames win over wildcards, and longer
+// wildcards win over shorter ones. Hostnames are compared without their
+// port and case-insensitively.
+type Dispatcher struct {
+	hosts     map[string]server.Handler
+	wildcards map[string]server.Handler
+
+	// Default is called when no pattern matches the request host.
+	Default server.Handler
+}
+
+func New() *Dispatcher {
+	return &Dispatcher{
+		hosts:     make(map[string]server.Handler),
+		wildcards: make(map[string]server.Handler),
+		Default:   misdirected,
+	}
+}
+
+// Handle registers handler for pattern. It panics if the pattern is empty
+// or already registered.
+func (d *Dispatcher) Handle(pattern string, handler server.Handler) {
+	pattern = normalize(pattern)
+	hosts := d.hosts
+	if suffix, ok := strings.CutPrefix(pattern, "*."); ok {
+		hosts = d.wildcards
+		pattern = suffix
+	}
+	if pattern == "" || strings.Contains(pattern, "*") {
+		panic("vhost: invalid pattern: " + pattern)
+	}
+	if _, ok := hosts[pattern]; ok {
+		panic("vhost: duplicate host: " + pattern)
+	}
+	hosts[pattern] = handler
+}
+
+// Dispatch is a server.Handler that passes req to the handler registered
+// for its host.
+func (d *Dispatcher) Dispatch(w *response.Writer, req *request.Request) {
+	d.find(req.Host)(w, req)
+}
+
+func (d *Dispatcher) find(host string) server.Handler {
+	host = normalize(hostname(host))
+	if handler, ok := d.hosts[host]; ok {
+		return handler
+	}
+	for {
+		_, parent, ok := strings.Cut(host, ".")
+		if !ok {
+			return d.Default
+		}
+		if handler, ok := d.wildcards[parent]; ok {
+			return handler
+		}
+		host = parent
+	}
+}
+
+// hostname strips the port, if any, from host.
+func hostname(host string) string {
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		return h
+	}
+	return strings.Trim(host, "[]")
+}
+
+// normalize lowercases host and drops the trailing dot of a fully
+// qualified name.
+func normalize(host string) string {
+	return strings.TrimSuffix(strings.ToLower(host), ".")
+}
+
+func misdirected(w *response.Writer, _ *request.Request) {
+	w.SetStatus(response.StatusMisdirectedRequest)
+	w.Header().Set("content-type", "text/plain")
+	w.Write([]byte("421 Misdirected Request\n"))
+}
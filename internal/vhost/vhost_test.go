@@ -0,0 +1,80 @@
+package vhost
+
+import (
+	"bytes"
+	"go-http-server/internal/request"
+	"go-http-server/internal/response"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func serve(t *testing.T, d *Dispatcher, head string) string {
+	req, err := request.RequestFromReader(strings.NewReader(head + "\r\n\r\n"))
+	require.NoError(t, err)
+
+	buf := &bytes.Buffer{}
+	w := response.NewWriter(buf)
+	d.Dispatch(w, req)
+	require.NoError(t, w.Finish())
+	return buf.String()
+}
+
+func reply(body string) func(w *response.Writer, req *request.Request) {
+	return func(w *response.Writer, req *request.Request) {
+		w.WriteStatusLine(response.StatusOK)
+		w.WriteHeaders(response.GetDefaultHeaders(len(body)))
+		w.WriteBody([]byte(body))
+	}
+}
+
+func TestDispatch(t *testing.T) {
+	d := New()
+	d.Handle("example.com", reply("apex"))
+	d.Handle("www.example.com", reply("www"))
+	d.Handle("*.example.com", reply("sub"))
+	d.Handle("*.api.example.com", reply("api"))
+
+	// Test: Exact host
+	assert.True(t, strings.HasSuffix(serve(t, d, "GET / HTTP/1.1\r\nHost: example.com"), "\r\n\r\napex"))
+
+	// Test: Exact host wins over wildcard
+	assert.True(t, strings.HasSuffix(serve(t, d, "GET / HTTP/1.1\r\nHost: www.example.com"), "\r\n\r\nwww"))
+
+	// Test: Port and case are ignored
+	assert.True(t, strings.HasSuffix(serve(t, d, "GET / HTTP/1.1\r\nHost: WWW.Example.com:8080"), "\r\n\r\nwww"))
+
+	// Test: Trailing dot is ignored
+	assert.True(t, strings.HasSuffix(serve(t, d, "GET / HTTP/1.1\r\nHost: example.com."), "\r\n\r\napex"))
+
+	// Test: Wildcard subdomain
+	assert.True(t, strings.HasSuffix(serve(t, d, "GET / HTTP/1.1\r\nHost: blog.example.com"), "\r\n\r\nsub"))
+	assert.True(t, strings.HasSuffix(serve(t, d, "GET / HTTP/1.1\r\nHost: a.b.example.com"), "\r\n\r\nsub"))
+
+	// Test: Longer wildcard wins
+	assert.True(t, strings.HasSuffix(serve(t, d, "GET / HTTP/1.1\r\nHost: v1.api.example.com"), "\r\n\r\napi"))
+
+	// Test: Absolute-form target picks the host
+	assert.True(t, strings.HasSuffix(serve(t, d, "GET http://www.example.com/ HTTP/1.1\r\nHost: example.com"), "\r\n\r\nwww"))
+
+	// Test: Unknown host
+	assert.True(t, strings.HasPrefix(serve(t, d, "GET / HTTP/1.1\r\nHost: other.org"), "HTTP/1.1 421 Misdirected Request\r\n"))
+
+	// Test: Default handler
+	d.Default = reply("default")
+	assert.True(t, strings.HasSuffix(serve(t, d, "GET / HTTP/1.1\r\nHost: other.org"), "\r\n\r\ndefault"))
+	assert.True(t, strings.HasSuffix(serve(t, d, "GET / HTTP/1.0"), "\r\n\r\ndefault"))
+}
+
+func TestHandlePanics(t *testing.T) {
+	d := New()
+	d.Handle("example.com", reply("apex"))
+	d.Handle("*.example.com", reply("sub"))
+
+	assert.Panics(t, func() { d.Handle("Example.com", reply("again")) })
+	assert.Panics(t, func() { d.Handle("*.example.com", reply("again")) })
+	assert.Panics(t, func() { d.Handle("", reply("empty")) })
+	assert.Panics(t, func() { d.Handle("a.*.com", reply("inner")) })
+}
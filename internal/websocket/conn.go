@@ -0,0 +1,322 @@
+package websocket
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"sync"
+	"time"
+	"unicode/utf8"
+)
+
+// MessageType is the type of a data message.
+type MessageType int
+
+const (
+	TextMessage   MessageType = MessageType(opText)
+	BinaryMessage MessageType = MessageType(opBinary)
+)
+
+// Close codes from RFC 6455 7.4.1.
+const (
+	CloseNormalClosure    = 1000
+	CloseGoingAway        = 1001
+	CloseProtocolError    = 1002
+	CloseUnsupportedData  = 1003
+	CloseNoStatusReceived = 1005
+	CloseInvalidPayload   = 1007
+	ClosePolicyViolation  = 1008
+	CloseMessageTooBig    = 1009
+	CloseInternalError    = 1011
+)
+
+// closeTimeout is how long Close waits for the peer to answer its close
+// frame.
+const closeTimeout = 5 * time.Second
+
+var (
+	// ErrClosed is returned by writes after a close frame has been sent.
+	ErrClosed = errors.New("websocket error: connection closed")
+	// ErrMessageTooLarge is returned by ReadMessage for a message over the
+	// maximum message size.
+	ErrMessageTooLarge = errors.New("websocket error: message too large")
+)
+
+// CloseError is returned by ReadMessage once the peer has closed the
+// connection. Code is CloseNoStatusReceived if the peer sent no code.
+type CloseError struct {
+	Code   int
+	Reason string
+}
+
+func (e *CloseError) Error() string {
+	return fmt.Sprintf("websocket: closed with code %d %s", e.Code, e.Reason)
+}
+
+// Conn is a WebSocket connection on the server side. One goroutine may
+// read while others write.
+type Conn struct {
+	conn           net.Conn
+	reader         *bufio.Reader
+	maxMessageSize int
+	fragmentSize   int
+
+	// readErr ends every read once the connection has failed or closed.
+	readErr error
+
+	writeMu   sync.Mutex
+	closeSent bool
+
+	closeOnce sync.Once
+	closeErr  error
+}
+
+// ReadMessage returns the next data message, reassembled from its
+// fragments. Pings are answered and pongs are skipped along the way. When
+// the peer closes the connection, the close frame is echoed and a
+// *CloseError is returned. Protocol violations close the connection with
+// the matching close code.
+func (c *Conn) ReadMessage() (MessageType, []byte, error) {
+	if c.readErr != nil {
+		return 0, nil, c.readErr
+	}
+	typ, msg, err := c.readMessage()
+	if err != nil {
+		c.readErr = err
+	}
+	return typ, msg, err
+}
+
+func (c *Conn) readMessage() (MessageType, []byte, error) {
+	var typ MessageType
+	var msg []byte
+	for {
+		h, err := readFrameHeader(c.reader)
+		if errors.Is(err, errInvalidLength) {
+			return 0, nil, c.fail(CloseProtocolError, err)
+		}
+		if err != nil {
+			c.closeConn()
+			return 0, nil, err
+		}
+		if err := checkFrameHeader(h); err != nil {
+			return 0, nil, c.fail(CloseProtocolError, err)
+		}
+		if !isControl(h.opcode) && c.maxMessageSize > 0 && int64(len(msg))+h.length > int64(c.maxMessageSize) {
+			return 0, nil, c.fail(CloseMessageTooBig, ErrMessageTooLarge)
+		}
+
+		// The payload is read rather than allocated up front, since without
+		// a size limit the declared length can be anything.
+		payload, err := io.ReadAll(io.LimitReader(c.reader, h.length))
+		if err == nil && int64(len(payload)) < h.length {
+			err = io.ErrUnexpectedEOF
+		}
+		if err != nil {
+			c.closeConn()
+			return 0, nil, err
+		}
+		maskBytes(h.mask, payload)
+
+		switch h.opcode {
+		case opPing:
+			if err := c.writeFrame(true, opPong, payload); err != nil && !errors.Is(err, ErrClosed) {
+				return 0, nil, err
+			}
+			continue
+		case opPong:
+			continue
+		case opClose:
+			return 0, nil, c.handleClose(payload)
+		case opContinuation:
+			if typ == 0 {
+				return 0, nil, c.fail(CloseProtocolError, errors.New("websocket error: continuation without a message"))
+			}
+		case opText, opBinary:
+			if typ != 0 {
+				return 0, nil, c.fail(CloseProtocolError, errors.New("websocket error: new message inside a fragmented one"))
+			}
+			typ = MessageType(h.opcode)
+		}
+
+		msg = append(msg, payload...)
+		if h.fin {
+			if typ == TextMessage && !utf8.Valid(msg) {
+				return 0, nil, c.fail(CloseInvalidPayload, errors.New("websocket error: invalid UTF-8 in text message"))
+			}
+			if msg == nil {
+				msg = []byte{}
+			}
+			return typ, msg, nil
+		}
+	}
+}
+
+// checkFrameHeader enforces the framing rules for frames from a client.
+func checkFrameHeader(h frameHeader) error {
+	switch {
+	case h.rsv != 0:
+		return errors.New("websocket error: reserved bits set without an extension")
+	case !h.masked:
+		return errors.New("websocket error: unmasked client frame")
+	case h.opcode > opBinary && !isControl(h.opcode), h.opcode > opPong:
+		return fmt.Errorf("websocket error: reserved opcode %#x", h.opcode)
+	case isControl(h.opcode) && (!h.fin || h.length > maxControlPayload):
+		return errors.New("websocket error: fragmented or oversized control frame")
+	}
+	return nil
+}
+
+// handleClose answers a close frame from the peer and closes the
+// connection.
+func (c *Conn) handleClose(payload []byte) error {
+	closeErr := &CloseError{Code: CloseNoStatusReceived}
+	switch {
+	case len(payload) == 1:
+		return c.fail(CloseProtocolError, errors.New("websocket error: truncated close code"))
+	case len(payload) >= 2:
+		closeErr.Code = int(binary.BigEndian.Uint16(payload))
+		closeErr.Reason = string(payload[2:])
+		if !isValidCloseCode(closeErr.Code) {
+			return c.fail(CloseProtocolError, fmt.Errorf("websocket error: invalid close code %d", closeErr.Code))
+		}
+		if !utf8.ValidString(closeErr.Reason) {
+			return c.fail(CloseInvalidPayload, errors.New("websocket error: invalid UTF-8 in close reason"))
+		}
+	}
+
+	// Echo the status code, as RFC 6455 5.5.1 suggests.
+	c.writeFrame(true, opClose, payload[:min(len(payload), 2)])
+	c.closeConn()
+	return closeErr
+}
+
+// fail closes the connection with code after a protocol error and returns
+// err.
+func (c *Conn) fail(code int, err error) error {
+	c.WriteClose(code, "")
+	c.closeConn()
+	return err
+}
+
+// WriteMessage sends a data message, split into fragments if
+// WithWriteFragmentSize was given. Text messages must be valid UTF-8.
+func (c *Conn) WriteMessage(typ MessageType, data []byte) error {
+	if typ != TextMessage && typ != BinaryMessage {
+		return fmt.Errorf("websocket error: invalid message type %d", typ)
+	}
+	if typ == TextMessage && !utf8.Valid(data) {
+		return errors.New("websocket error: invalid UTF-8 in text message")
+	}
+
+	c.writeMu.Lock()
+	defer c.writeMu.Unlock()
+	if c.closeSent {
+		return ErrClosed
+	}
+
+	opcode := byte(typ)
+	for {
+		fragment := data
+		if c.fragmentSize > 0 && len(fragment) > c.fragmentSize {
+			fragment = data[:c.fragmentSize]
+		}
+		data = data[len(fragment):]
+		if _, err := c.conn.Write(appendFrame(nil, len(data) == 0, opcode, fragment)); err != nil {
+			return err
+		}
+		if len(data) == 0 {
+			return nil
+		}
+		opcode = opContinuation
+	}
+}
+
+// WritePing sends a ping with up to 125 bytes of data. The peer's pong is
+// consumed by ReadMessage.
+func (c *Conn) WritePing(data []byte) error {
+	if len(data) > maxControlPayload {
+		return errors.New("websocket error: ping payload too long")
+	}
+	return c.writeFrame(true, opPing, data)
+}
+
+// WriteClose sends a close frame without waiting for the answer, which
+// ReadMessage then reports as a *CloseError. Use it to close from a
+// goroutine other than the reading one. Nothing can be written afterwards.
+func (c *Conn) WriteClose(code int, reason string) error {
+	if !isValidCloseCode(code) {
+		return fmt.Errorf("websocket error: invalid close code %d", code)
+	}
+	if len(reason) > maxControlPayload-2 {
+		return errors.New("websocket error: close reason too long")
+	}
+	if !utf8.ValidString(reason) {
+		return errors.New("websocket error: invalid UTF-8 in close reason")
+	}
+	payload := binary.BigEndian.AppendUint16(nil, uint16(code))
+	return c.writeFrame(true, opClose, append(payload, reason...))
+}
+
+// Close closes the connection normally. See CloseWithCode.
+func (c *Conn) Close() error {
+	return c.CloseWithCode(CloseNormalClosure, "")
+}
+
+// CloseWithCode runs the closing handshake: it sends a close frame and
+// reads until the peer answers, discarding data messages, for up to five
+// seconds. It must not be called while another goroutine is in
+// ReadMessage; use WriteClose there instead.
+func (c *Conn) CloseWithCode(code int, reason string) error {
+	if c.readErr != nil {
+		return c.closeConn()
+	}
+	if err := c.WriteClose(code, reason); err != nil && !errors.Is(err, ErrClosed) {
+		c.closeConn()
+		return err
+	}
+
+	c.conn.SetReadDeadline(time.Now().Add(closeTimeout))
+	for {
+		if _, _, err := c.ReadMessage(); err != nil {
+			break
+		}
+	}
+	return c.closeConn()
+}
+
+func (c *Conn) writeFrame(fin bool, opcode byte, payload []byte) error {
+	c.writeMu.Lock()
+	defer c.writeMu.Unlock()
+	if c.closeSent {
+		return ErrClosed
+	}
+	if opcode == opClose {
+		c.closeSent = true
+	}
+	_, err := c.conn.Write(appendFrame(nil, fin, opcode, payload))
+	return err
+}
+
+// closeConn closes the TCP connection once. The server closes first once
+// the closing handshake is done (RFC 6455 7.1.1).
+func (c *Conn) closeConn() error {
+	c.closeOnce.Do(func() {
+		c.closeErr = c.conn.Close()
+	})
+	return c.closeErr
+}
+
+// isValidCloseCode reports whether code may be sent in a close frame.
+func isValidCloseCode(code int) bool {
+	switch {
+	case code >= 1000 && code <= 1003, code >= 1007 && code <= 1011:
+		return true
+	case code >= 3000 && code <= 4999:
+		return true
+	}
+	return false
+}
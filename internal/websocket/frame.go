@@ -0,0 +1,103 @@
+package websocket
+
+import (
+	"encoding/binary"
+	"errors"
+	"io"
+)
+
+const (
+	opContinuation byte = 0x0
+	opText         byte = 0x1
+	opBinary       byte = 0x2
+	opClose        byte = 0x8
+	opPing         byte = 0x9
+	opPong         byte = 0xa
+)
+
+var errInvalidLength = errors.New("websocket error: invalid frame length")
+
+// maxControlPayload is the payload limit of close, ping and pong frames.
+const maxControlPayload = 125
+
+type frameHeader struct {
+	fin    bool
+	rsv    byte
+	opcode byte
+	masked bool
+	mask   [4]byte
+	length int64
+}
+
+func isControl(opcode byte) bool {
+	return opcode&0x8 != 0
+}
+
+// readFrameHeader reads the header of one frame (RFC 6455 5.2).
+func readFrameHeader(r io.Reader) (frameHeader, error) {
+	var b [8]byte
+	if _, err := io.ReadFull(r, b[:2]); err != nil {
+		return frameHeader{}, err
+	}
+
+	h := frameHeader{
+		fin:    b[0]&0x80 != 0,
+		rsv:    b[0] & 0x70,
+		opcode: b[0] & 0x0f,
+		masked: b[1]&0x80 != 0,
+		length: int64(b[1] & 0x7f),
+	}
+
+	switch h.length {
+	case 126:
+		if _, err := io.ReadFull(r, b[:2]); err != nil {
+			return frameHeader{}, err
+		}
+		h.length = int64(binary.BigEndian.Uint16(b[:2]))
+	case 127:
+		if _, err := io.ReadFull(r, b[:8]); err != nil {
+			return frameHeader{}, err
+		}
+		n := binary.BigEndian.Uint64(b[:8])
+		if n>>63 != 0 {
+			return frameHeader{}, errInvalidLength
+		}
+		h.length = int64(n)
+	}
+
+	if h.masked {
+		if _, err := io.ReadFull(r, h.mask[:]); err != nil {
+			return frameHeader{}, err
+		}
+	}
+	return h, nil
+}
+
+// appendFrame appends an unmasked frame, as sent by a server.
+func appendFrame(b []byte, fin bool, opcode byte, payload []byte) []byte {
+	first := opcode
+	if fin {
+		first |= 0x80
+	}
+	b = append(b, first)
+
+	switch n := len(payload); {
+	case n <= 125:
+		b = append(b, byte(n))
+	case n <= 0xffff:
+		b = append(b, 126)
+		b = binary.BigEndian.AppendUint16(b, uint16(n))
+	default:
+		b = append(b, 127)
+		b = binary.BigEndian.AppendUint64(b, uint64(n))
+	}
+	return append(b, payload...)
+}
+
+// maskBytes applies the masking key to payload in place. Masking and
+// unmasking are the same operation.
+func maskBytes(mask [4]byte, payload []byte) {
+	for i := range payload {
+		payload[i] ^= mask[i%4]
+	}
+}
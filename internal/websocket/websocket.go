@@ -0,0 +1,121 @@
+package websocket
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/sha1"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"go-http-server/internal/request"
+	"go-http-server/internal/response"
+	"io"
+	"strings"
+)
+
+// acceptGUID is appended to the client key to compute Sec-WebSocket-Accept
+// (RFC 6455 4.2.2).
+const acceptGUID = "258EAFA5-E914-47DA-95CA-C5AB0DC85B11"
+
+// DefaultMaxMessageSize is the largest message ReadMessage accepts unless
+// WithMaxMessageSize says otherwise.
+const DefaultMaxMessageSize = 1 << 20
+
+var errBadVersion = errors.New("websocket error: unsupported version")
+
+// Option configures a Conn created by Upgrade.
+type Option func(*Conn)
+
+// WithMaxMessageSize limits the size of a message read by ReadMessage,
+// across all its fragments. Larger messages close the connection with
+// CloseMessageTooBig. Zero means unlimited.
+func WithMaxMessageSize(n int) Option {
+	return func(c *Conn) {
+		c.maxMessageSize = n
+	}
+}
+
+// WithWriteFragmentSize splits messages written by WriteMessage into
+// fragments of at most n bytes. Zero, the default, sends every message as
+// a single frame.
+func WithWriteFragmentSize(n int) Option {
+	return func(c *Conn) {
+		c.fragmentSize = n
+	}
+}
+
+// Upgrade completes the opening handshake for req and takes over the
+// connection. If the request is not a valid WebSocket handshake, it
+// answers with 400 Bad Request, or 426 Upgrade Required for an unsupported
+// version, and returns an error; the handler should then return.
+func Upgrade(w *response.Writer, req *request.Request, opts ...Option) (*Conn, error) {
+	key, err := checkHandshake(req)
+	if err != nil {
+		status := response.StatusBadRequest
+		if errors.Is(err, errBadVersion) {
+			status = response.StatusUpgradeRequired
+			w.Header().Set("sec-websocket-version", "13")
+		}
+		w.SetStatus(status)
+		w.Header().Set("content-type", "text/plain")
+		fmt.Fprintf(w, "%d %s\n", status, response.StatusText(status))
+		return nil, err
+	}
+
+	// The status is only recorded for logging; the response itself is
+	// written to the hijacked connection.
+	w.SetStatus(response.StatusSwitchingProtocols)
+	netConn, buffered, err := w.Hijack()
+	if err != nil {
+		w.SetStatus(response.StatusInternalServerError)
+		return nil, err
+	}
+
+	_, err = fmt.Fprintf(netConn, "HTTP/1.1 %d %s\r\nUpgrade: websocket\r\nConnection: Upgrade\r\nSec-WebSocket-Accept: %s\r\n\r\n",
+		response.StatusSwitchingProtocols, response.StatusText(response.StatusSwitchingProtocols), acceptKey(key))
+	if err != nil {
+		netConn.Close()
+		return nil, err
+	}
+
+	c := &Conn{
+		conn:           netConn,
+		reader:         bufio.NewReader(io.MultiReader(bytes.NewReader(buffered), netConn)),
+		maxMessageSize: DefaultMaxMessageSize,
+	}
+	for _, opt := range opts {
+		opt(c)
+	}
+	return c, nil
+}
+
+// checkHandshake validates the client handshake (RFC 6455 4.2.1) and
+// returns its Sec-WebSocket-Key.
+func checkHandshake(req *request.Request) (string, error) {
+	if req.RequestLine.Method != "GET" {
+		return "", errors.New("websocket error: handshake must use GET")
+	}
+	if req.RequestLine.HttpVersion != request.HTTP11 {
+		return "", errors.New("websocket error: handshake must use HTTP/1.1")
+	}
+	if !req.Headers.HasToken("upgrade", "websocket") {
+		return "", errors.New("websocket error: missing Upgrade: websocket")
+	}
+	if !req.Headers.HasToken("connection", "upgrade") {
+		return "", errors.New("websocket error: missing Connection: Upgrade")
+	}
+	if req.Headers.Get("sec-websocket-version") != "13" {
+		return "", errBadVersion
+	}
+
+	key := strings.TrimSpace(req.Headers.Get("sec-websocket-key"))
+	if decoded, err := base64.StdEncoding.DecodeString(key); err != nil || len(decoded) != 16 {
+		return "", errors.New("websocket error: invalid Sec-WebSocket-Key")
+	}
+	return key, nil
+}
+
+func acceptKey(key string) string {
+	sum := sha1.Sum([]byte(key + acceptGUID))
+	return base64.StdEncoding.EncodeToString(sum[:])
+}
@@ -0,0 +1,337 @@
+package websocket
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"go-http-server/internal/request"
+	"go-http-server/internal/response"
+	"io"
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const handshake = "GET /chat HTTP/1.1\r\n" +
+	"Host: localhost:8080\r\n" +
+	"Upgrade: websocket\r\n" +
+	"Connection: keep-alive, Upgrade\r\n" +
+	"Sec-WebSocket-Key: dGhlIHNhbXBsZSBub25jZQ==\r\n" +
+	"Sec-WebSocket-Version: 13\r\n" +
+	"\r\n"
+
+// tcpPair returns both ends of a loopback TCP connection.
+func tcpPair(t *testing.T) (net.Conn, net.Conn) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	defer ln.Close()
+
+	client, err := net.Dial("tcp", ln.Addr().String())
+	require.NoError(t, err)
+	server, err := ln.Accept()
+	require.NoError(t, err)
+	t.Cleanup(func() {
+		client.Close()
+		server.Close()
+	})
+	client.SetDeadline(time.Now().Add(5 * time.Second))
+	return server, client
+}
+
+// upgrade runs Upgrade on the server end of a new connection. buffered is
+// handed over as if the server had read it past the handshake.
+func upgrade(t *testing.T, buffered string, opts ...Option) (*Conn, *bufio.Reader, net.Conn) {
+	server, client := tcpPair(t)
+	req, err := request.RequestFromReader(strings.NewReader(handshake))
+	require.NoError(t, err)
+
+	w := response.NewWriter(server)
+	w.SetHijacker(func() (net.Conn, []byte, error) {
+		return server, []byte(buffered), nil
+	})
+	c, err := Upgrade(w, req, opts...)
+	require.NoError(t, err)
+	assert.Equal(t, response.StatusSwitchingProtocols, w.Status())
+
+	reader := bufio.NewReader(client)
+	for {
+		line, err := reader.ReadString('\n')
+		require.NoError(t, err)
+		if line == "\r\n" {
+			break
+		}
+	}
+	return c, reader, client
+}
+
+// clientFrame builds a masked frame, as sent by a client.
+func clientFrame(fin bool, opcode byte, payload []byte) []byte {
+	mask := [4]byte{1, 2, 3, 4}
+	masked := bytes.Clone(payload)
+	maskBytes(mask, masked)
+
+	b := appendFrame(nil, fin, opcode, masked)
+	headerLen := len(b) - len(masked)
+	b[1] |= 0x80
+	out := append([]byte{}, b[:headerLen]...)
+	out = append(out, mask[:]...)
+	return append(out, masked...)
+}
+
+func closePayload(code int, reason string) []byte {
+	return append(binary.BigEndian.AppendUint16(nil, uint16(code)), reason...)
+}
+
+// readServerFrame reads one frame sent by the server.
+func readServerFrame(t *testing.T, r *bufio.Reader) (frameHeader, []byte) {
+	h, err := readFrameHeader(r)
+	require.NoError(t, err)
+	assert.False(t, h.masked)
+	payload := make([]byte, h.length)
+	_, err = io.ReadFull(r, payload)
+	require.NoError(t, err)
+	return h, payload
+}
+
+func TestUpgrade(t *testing.T) {
+	// Test: Handshake response
+	server, client := tcpPair(t)
+	req, err := request.RequestFromReader(strings.NewReader(handshake))
+	require.NoError(t, err)
+	w := response.NewWriter(server)
+	w.SetHijacker(func() (net.Conn, []byte, error) {
+		return server, nil, nil
+	})
+	_, err = Upgrade(w, req)
+	require.NoError(t, err)
+	resp := make([]byte, 129)
+	_, err = io.ReadFull(client, resp)
+	require.NoError(t, err)
+	assert.Equal(t, "HTTP/1.1 101 Switching Protocols\r\n"+
+		"Upgrade: websocket\r\n"+
+		"Connection: Upgrade\r\n"+
+		"Sec-WebSocket-Accept: s3pPLMBiTxaQ9kYGzzhZRbK+xOo=\r\n"+
+		"\r\n", string(resp))
+
+	// Test: Bytes read past the handshake are the first frame
+	c, _, _ := upgrade(t, string(clientFrame(true, opText, []byte("early"))))
+	typ, msg, err := c.ReadMessage()
+	require.NoError(t, err)
+	assert.Equal(t, TextMessage, typ)
+	assert.Equal(t, "early", string(msg))
+}
+
+func TestUpgradeRejected(t *testing.T) {
+	reject := func(raw string) string {
+		req, err := request.RequestFromReader(strings.NewReader(raw))
+		require.NoError(t, err)
+		buf := &bytes.Buffer{}
+		w := response.NewWriter(buf)
+		_, err = Upgrade(w, req)
+		require.Error(t, err)
+		require.NoError(t, w.Finish())
+		return buf.String()
+	}
+
+	// Test: Invalid handshakes
+	for _, raw := range []string{
+		strings.Replace(handshake, "GET", "POST", 1),
+		strings.Replace(handshake, "Upgrade: websocket\r\n", "", 1),
+		strings.Replace(handshake, "keep-alive, Upgrade", "keep-alive", 1),
+		strings.Replace(handshake, "dGhlIHNhbXBsZSBub25jZQ==", "c2hvcnQ=", 1),
+		strings.Replace(handshake, "Sec-WebSocket-Key: dGhlIHNhbXBsZSBub25jZQ==\r\n", "", 1),
+	} {
+		assert.True(t, strings.HasPrefix(reject(raw), "HTTP/1.1 400 Bad Request\r\n"), raw)
+	}
+
+	// Test: Unsupported version
+	out := reject(strings.Replace(handshake, "Version: 13", "Version: 8", 1))
+	assert.True(t, strings.HasPrefix(out, "HTTP/1.1 426 Upgrade Required\r\n"))
+	assert.Contains(t, out, "\r\nSec-Websocket-Version: 13\r\n")
+
+	// Test: Writer that cannot be hijacked
+	req, err := request.RequestFromReader(strings.NewReader(handshake))
+	require.NoError(t, err)
+	w := response.NewWriter(&bytes.Buffer{})
+	_, err = Upgrade(w, req)
+	require.Error(t, err)
+	assert.Equal(t, response.StatusInternalServerError, w.Status())
+}
+
+func TestReadMessage(t *testing.T) {
+	c, reader, client := upgrade(t, "")
+
+	// Test: Fragmented text with a ping in between
+	client.Write(clientFrame(false, opText, []byte("hel")))
+	client.Write(clientFrame(true, opPing, []byte("are you there")))
+	client.Write(clientFrame(false, opContinuation, []byte("lo ")))
+	client.Write(clientFrame(true, opContinuation, []byte("wörld")))
+	typ, msg, err := c.ReadMessage()
+	require.NoError(t, err)
+	assert.Equal(t, TextMessage, typ)
+	assert.Equal(t, "hello wörld", string(msg))
+
+	h, payload := readServerFrame(t, reader)
+	assert.Equal(t, opPong, h.opcode)
+	assert.Equal(t, "are you there", string(payload))
+
+	// Test: Binary message with a 16-bit length, pong skipped
+	data := bytes.Repeat([]byte{0xff}, 300)
+	client.Write(clientFrame(true, opPong, nil))
+	client.Write(clientFrame(true, opBinary, data))
+	typ, msg, err = c.ReadMessage()
+	require.NoError(t, err)
+	assert.Equal(t, BinaryMessage, typ)
+	assert.Equal(t, data, msg)
+
+	// Test: Empty message
+	client.Write(clientFrame(true, opText, nil))
+	_, msg, err = c.ReadMessage()
+	require.NoError(t, err)
+	assert.Empty(t, msg)
+}
+
+func TestProtocolErrors(t *testing.T) {
+	unmasked := appendFrame(nil, true, opText, []byte("hi"))
+	rsv := clientFrame(true, opText, []byte("hi"))
+	rsv[0] |= 0x40
+
+	for _, test := range []struct {
+		name  string
+		input []byte
+		code  int
+	}{
+		{"unmasked frame", unmasked, CloseProtocolError},
+		{"reserved bits", rsv, CloseProtocolError},
+		{"reserved opcode", clientFrame(true, 0x3, nil), CloseProtocolError},
+		{"continuation first", clientFrame(true, opContinuation, []byte("x")), CloseProtocolError},
+		{"fragmented ping", clientFrame(false, opPing, nil), CloseProtocolError},
+		{"long ping", clientFrame(true, opPing, make([]byte, 126)), CloseProtocolError},
+		{"message inside fragment", append(clientFrame(false, opText, []byte("a")), clientFrame(true, opText, []byte("b"))...), CloseProtocolError},
+		{"invalid utf-8", clientFrame(true, opText, []byte{0xff, 0xfe}), CloseInvalidPayload},
+		{"too large", clientFrame(true, opBinary, make([]byte, 11)), CloseMessageTooBig},
+		{"too large across fragments", append(clientFrame(false, opBinary, make([]byte, 6)), clientFrame(true, opContinuation, make([]byte, 6))...), CloseMessageTooBig},
+		{"invalid close code", clientFrame(true, opClose, closePayload(1005, "")), CloseProtocolError},
+		{"truncated close code", clientFrame(true, opClose, []byte{3}), CloseProtocolError},
+	} {
+		// Test: Each violation closes with the matching code
+		c, reader, client := upgrade(t, "", WithMaxMessageSize(10))
+		client.Write(test.input)
+		_, _, err := c.ReadMessage()
+		require.Error(t, err, test.name)
+		if test.code == CloseMessageTooBig {
+			assert.ErrorIs(t, err, ErrMessageTooLarge)
+		}
+
+		h, payload := readServerFrame(t, reader)
+		assert.Equal(t, opClose, h.opcode, test.name)
+		assert.Equal(t, test.code, int(binary.BigEndian.Uint16(payload)), test.name)
+		_, err = reader.ReadByte()
+		assert.ErrorIs(t, err, io.EOF, test.name)
+
+		_, _, again := c.ReadMessage()
+		assert.Error(t, again)
+		assert.ErrorIs(t, c.WriteMessage(TextMessage, []byte("x")), ErrClosed)
+	}
+}
+
+func TestWriteMessage(t *testing.T) {
+	// Test: Single frame
+	c, reader, _ := upgrade(t, "")
+	require.NoError(t, c.WriteMessage(TextMessage, []byte("hello")))
+	h, payload := readServerFrame(t, reader)
+	assert.True(t, h.fin)
+	assert.Equal(t, opText, h.opcode)
+	assert.Equal(t, "hello", string(payload))
+
+	// Test: 64-bit length
+	data := bytes.Repeat([]byte("a"), 70000)
+	require.NoError(t, c.WriteMessage(BinaryMessage, data))
+	h, payload = readServerFrame(t, reader)
+	assert.Equal(t, int64(70000), h.length)
+	assert.Equal(t, data, payload)
+
+	// Test: Ping
+	require.NoError(t, c.WritePing([]byte("p")))
+	h, payload = readServerFrame(t, reader)
+	assert.Equal(t, opPing, h.opcode)
+	assert.Equal(t, "p", string(payload))
+	require.Error(t, c.WritePing(make([]byte, 126)))
+
+	// Test: Invalid messages
+	require.Error(t, c.WriteMessage(TextMessage, []byte{0xff}))
+	require.Error(t, c.WriteMessage(MessageType(opPing), nil))
+
+	// Test: Fragmented
+	c, reader, _ = upgrade(t, "", WithWriteFragmentSize(4))
+	require.NoError(t, c.WriteMessage(TextMessage, []byte("hello world")))
+	for _, want := range []struct {
+		fin     bool
+		opcode  byte
+		payload string
+	}{
+		{false, opText, "hell"},
+		{false, opContinuation, "o wo"},
+		{true, opContinuation, "rld"},
+	} {
+		h, payload = readServerFrame(t, reader)
+		assert.Equal(t, want.fin, h.fin)
+		assert.Equal(t, want.opcode, h.opcode)
+		assert.Equal(t, want.payload, string(payload))
+	}
+}
+
+func TestCloseHandshake(t *testing.T) {
+	// Test: Client closes, server echoes the code
+	c, reader, client := upgrade(t, "")
+	client.Write(clientFrame(true, opClose, closePayload(CloseGoingAway, "bye")))
+	_, _, err := c.ReadMessage()
+	var closeErr *CloseError
+	require.ErrorAs(t, err, &closeErr)
+	assert.Equal(t, CloseGoingAway, closeErr.Code)
+	assert.Equal(t, "bye", closeErr.Reason)
+
+	h, payload := readServerFrame(t, reader)
+	assert.Equal(t, opClose, h.opcode)
+	assert.Equal(t, closePayload(CloseGoingAway, ""), payload)
+	_, err = reader.ReadByte()
+	assert.ErrorIs(t, err, io.EOF)
+	assert.ErrorIs(t, c.WriteMessage(TextMessage, []byte("x")), ErrClosed)
+	assert.NoError(t, c.Close())
+
+	// Test: Close without a code
+	c, reader, client = upgrade(t, "")
+	client.Write(clientFrame(true, opClose, nil))
+	_, _, err = c.ReadMessage()
+	require.ErrorAs(t, err, &closeErr)
+	assert.Equal(t, CloseNoStatusReceived, closeErr.Code)
+	h, payload = readServerFrame(t, reader)
+	assert.Equal(t, opClose, h.opcode)
+	assert.Empty(t, payload)
+
+	// Test: Server closes and waits for the answer
+	c, reader, client = upgrade(t, "")
+	done := make(chan error)
+	go func() {
+		done <- c.CloseWithCode(ClosePolicyViolation, "nope")
+	}()
+	h, payload = readServerFrame(t, reader)
+	assert.Equal(t, opClose, h.opcode)
+	assert.Equal(t, closePayload(ClosePolicyViolation, "nope"), payload)
+	client.Write(clientFrame(true, opText, []byte("late")))
+	client.Write(clientFrame(true, opClose, closePayload(ClosePolicyViolation, "")))
+	require.NoError(t, <-done)
+	_, err = reader.ReadByte()
+	assert.ErrorIs(t, err, io.EOF)
+
+	// Test: Invalid close arguments
+	c, _, _ = upgrade(t, "")
+	require.Error(t, c.WriteClose(CloseNoStatusReceived, ""))
+	require.Error(t, c.WriteClose(CloseNormalClosure, strings.Repeat("a", 124)))
+	require.NoError(t, c.WriteClose(CloseNormalClosure, ""))
+	assert.ErrorIs(t, c.WriteClose(CloseNormalClosure, ""), ErrClosed)
+}